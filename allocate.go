@@ -0,0 +1,71 @@
+package main
+
+import "fmt"
+
+// What the audio of a clip mostly is, indexes match audioContentNames
+const (
+	contentSpeech = iota
+	contentMixed
+	contentMusic
+)
+
+var audioContentNames = []string{"Speech", "Gameplay", "Music"}
+
+// A bitrate the allocator can pick
+type audioStep struct {
+	Bitrate int // kb/s
+	Mono    bool
+}
+
+// Steps from smallest to largest for each content type. Speech never needs stereo
+// and music sounds wrong in mono so each ladder only has what suits the content
+var audioLadders = [][]audioStep{
+	contentSpeech: {{24, true}, {32, true}, {48, true}, {64, true}},
+	contentMixed:  {{32, true}, {48, true}, {64, false}, {96, false}, {112, false}},
+	contentMusic:  {{48, false}, {64, false}, {96, false}, {128, false}},
+}
+
+// Share of the total bitrate the audio should get at most
+var audioShares = []float32{
+	contentSpeech: 0.12,
+	contentMixed:  0.18,
+	contentMusic:  0.25,
+}
+
+// The allocator's pick and why it made it
+type AudioAllocation struct {
+	Bitrate int
+	Mono    bool
+	Reason  string
+}
+
+// Picks the audio bitrate and channels from the total bitrate of the output and what the audio is.
+// AAC needs more bitrate than Opus to sound as good so it never goes below AAC's acceptable bitrate
+func allocateAudio(totalBitrate float32, content int, audioCodec int) AudioAllocation {
+	ladder := audioLadders[content]
+	share := totalBitrate * audioShares[content]
+
+	step := ladder[0]
+	for _, s := range ladder {
+		if float32(s.Bitrate) <= share {
+			step = s
+		}
+	}
+	reason := fmt.Sprintf("%.0f%% of the %.0f Kb/s total for %s", audioShares[content]*100, totalBitrate, audioContentNames[content])
+	if float32(ladder[0].Bitrate) > share {
+		reason = fmt.Sprintf("the lowest bitrate for %s, the %.0f Kb/s total is very small", audioContentNames[content], totalBitrate)
+	}
+	if audioCodec == videoAudioAAC && float32(step.Bitrate) < audioFormats[audioAAC].Acceptable {
+		step.Bitrate = int(audioFormats[audioAAC].Acceptable)
+		reason += ", raised to AAC's minimum"
+	}
+	return AudioAllocation{Bitrate: step.Bitrate, Mono: step.Mono, Reason: reason}
+}
+
+func (a AudioAllocation) String() string {
+	channels := "stereo"
+	if a.Mono {
+		channels = "mono"
+	}
+	return fmt.Sprintf("Audio: %d Kb/s %s, %s", a.Bitrate, channels, a.Reason)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ffmpeg "github.com/Gordon-T/ffmpeg-go"
+)
+
+// Animated image formats, indexes match animatedFormatNames
+const (
+	animatedWebP = iota
+	animatedAPNG
+	animatedGIF
+)
+
+var animatedFormatNames = []string{"WebP", "APNG", "GIF"}
+var animatedEncoders = []string{"libwebp_anim", "apng", "gif"}
+var animatedSuffixes = []string{"_anim.webp", "_apng.png", "_gif.gif"}
+
+// Max width options, indexes match animatedWidthNames
+var animatedWidths = []int{0, 640, 480, 320}
+var animatedWidthNames = []string{"Original", "640", "480", "320"}
+
+// Settings for an animated image, filled in from the Animated Image tab
+type AnimatedOptions struct {
+	Format      int
+	FPS         int
+	Width       int // 0 keeps the source width
+	Plays       int // 0 loops forever
+	Start       float64
+	Length      float64 // seconds of source, 0 runs to the end of the source
+	Duration    float64 // seconds of output, used for progress
+	TargetBytes int64   // 0 for no size target
+	Caption     CaptionOptions
+	Subtitles   SubtitleOptions
+	Playback    PlaybackOptions
+
+	SourceWidth  int
+	SourceHeight int
+}
+
+// One try at the animated image. Quality is for WebP, Colors for APNG and GIF, 0 keeps full color
+type animatedAttempt struct {
+	FPS     int
+	Width   int
+	Quality int
+	Colors  int
+}
+
+func (a animatedAttempt) String() string {
+	detail := fmt.Sprintf("quality %d", a.Quality)
+	if a.Quality == 0 {
+		detail = "full color"
+		if a.Colors > 0 {
+			detail = fmt.Sprintf("%d colors", a.Colors)
+		}
+	}
+	width := "original width"
+	if a.Width > 0 {
+		width = fmt.Sprintf("%dpx wide", a.Width)
+	}
+	return fmt.Sprintf("%d fps, %s, %s", a.FPS, width, detail)
+}
+
+// Lists the tries from best looking to smallest. The quality or colors go down first,
+// then the frame rate, then the size. Without a size target only the first try is made
+func animatedAttempts(opts AnimatedOptions) []animatedAttempt {
+	width := opts.Width
+	if width == 0 || width > opts.SourceWidth {
+		width = opts.SourceWidth
+	}
+	lowFPS := max(opts.FPS*2/3, 1)
+
+	var steps, lowSteps []animatedAttempt
+	if opts.Format == animatedWebP {
+		steps = []animatedAttempt{{Quality: 80}, {Quality: 65}, {Quality: 50}, {Quality: 35}}
+		lowSteps = []animatedAttempt{{Quality: 50}, {Quality: 35}}
+	} else {
+		steps = []animatedAttempt{{Colors: 256}, {Colors: 128}, {Colors: 64}, {Colors: 32}}
+		if opts.Format == animatedAPNG {
+			steps = append([]animatedAttempt{{}}, steps...)
+		}
+		lowSteps = []animatedAttempt{{Colors: 64}, {Colors: 32}}
+	}
+
+	var attempts []animatedAttempt
+	for _, s := range steps {
+		attempts = append(attempts, animatedAttempt{FPS: opts.FPS, Width: width, Quality: s.Quality, Colors: s.Colors})
+	}
+	if opts.TargetBytes == 0 {
+		return attempts[:1]
+	}
+	for _, w := range []int{width, evenSize(float64(width) * 0.75), evenSize(float64(width) * 0.5)} {
+		for _, s := range lowSteps {
+			attempts = append(attempts, animatedAttempt{FPS: lowFPS, Width: w, Quality: s.Quality, Colors: s.Colors})
+		}
+	}
+	return attempts
+}
+
+// Returns the ffmpeg arguments for one try at the animated image
+func animatedArgs(opts AnimatedOptions, attempt animatedAttempt) ffmpeg.KwArgs {
+	// The frame rate is set after the speed change so slow motion doesn't end up choppy
+	filters := []string{fmt.Sprintf("scale=%d:-2:flags=lanczos", attempt.Width)}
+	filters = append(filters, opts.Subtitles.filters()...)
+	filters = append(filters, opts.Playback.videoFilters(opts.Length)...)
+	filters = append(filters, fmt.Sprintf("fps=%d", attempt.FPS))
+
+	// The caption is drawn at the output size so it stays readable, before the palette is made
+	if opts.Caption.enabled() && opts.SourceWidth > 0 {
+		height := evenSize(float64(opts.SourceHeight) * float64(attempt.Width) / float64(opts.SourceWidth))
+		filters = append(filters, opts.Caption.filters(attempt.Width, height)...)
+	}
+	filter := strings.Join(filters, ",")
+	if overlay := opts.Subtitles.overlay(); overlay != "" {
+		filter = overlay + "," + filter
+	}
+	if attempt.Colors > 0 {
+		filter += fmt.Sprintf(",split[a][b];[a]palettegen=max_colors=%d:stats_mode=diff[p];[b][p]paletteuse=dither=bayer:bayer_scale=5", attempt.Colors)
+	}
+	ffmpegArguments := ffmpeg.KwArgs{
+		"filter_complex": filter,
+		"c:v":            animatedEncoders[opts.Format],
+		"an":             "",
+	}
+	if opts.Length > 0 {
+		ffmpegArguments["t"] = strconv.FormatFloat(opts.Playback.outputDuration(opts.Length), 'f', 3, 64)
+	}
+
+	// Each format counts loops differently, Plays is the number of times it is shown
+	switch opts.Format {
+	case animatedWebP:
+		ffmpegArguments["quality"] = strconv.Itoa(attempt.Quality)
+		ffmpegArguments["compression_level"] = "6"
+		ffmpegArguments["loop"] = strconv.Itoa(opts.Plays)
+	case animatedAPNG:
+		ffmpegArguments["f"] = "apng"
+		ffmpegArguments["plays"] = strconv.Itoa(opts.Plays)
+	default:
+		// gif counts repeats after the first play, 0 loops forever and -1 plays once
+		loop := opts.Plays - 1
+		switch opts.Plays {
+		case 0:
+			loop = 0
+		case 1:
+			loop = -1
+		}
+		ffmpegArguments["loop"] = strconv.Itoa(loop)
+	}
+	return ffmpegArguments
+}
+
+// Encodes the animated image, lowering quality, frame rate and size until it fits the target size.
+// Returns the output path, or "" if encoding failed, and a note on what it took to fit
+func animatedEncode(filePath string, opts AnimatedOptions) (string, string) {
+	var fileName string = filepath.Base(filePath)
+	var outputName = filepath.Dir(filePath) + `\` + strings.TrimSuffix(fileName, filepath.Ext(fileName)) + animatedSuffixes[opts.Format]
+
+	inputArgs := ffmpeg.KwArgs{}
+	if opts.Start > 0 {
+		inputArgs["ss"] = strconv.FormatFloat(opts.Start, 'f', 3, 64)
+	}
+	attempts := animatedAttempts(opts)
+	for i, attempt := range attempts {
+		attemptStatus = fmt.Sprintf("Try %d of %d, %s", i+1, len(attempts), attempt)
+		animatedErr := ffmpeg.Input(filePath, inputArgs).Output(outputName, animatedArgs(opts, attempt)).GlobalArgs("-progress", TempTCPProgress(opts.Duration)).OverWriteOutput().SetFfmpegPath("./ffmpeg.exe").ErrorToStdOut().Run()
+		if animatedErr != nil {
+			encodeError = true
+			log.Printf("Error occurred while encoding %s: %v", animatedFormatNames[opts.Format], animatedErr)
+			return "", ""
+		}
+		if opts.TargetBytes == 0 {
+			return outputName, ""
+		}
+		info, err := os.Stat(outputName)
+		if err != nil {
+			log.Println("Error checking animated image size:", err)
+			return "", ""
+		}
+		log.Printf("%s try %d: %v bytes", animatedFormatNames[opts.Format], i+1, info.Size())
+		if info.Size() <= opts.TargetBytes {
+			return outputName, fmt.Sprintf("Fit at %s after %d tries", attempt, i+1)
+		}
+	}
+	return outputName, fmt.Sprintf("Still too big at %s, try a shorter segment", attempts[len(attempts)-1])
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestAnimatedArgsPlays(t *testing.T) {
+	tests := []struct {
+		format int
+		plays  int
+		key    string
+		want   string
+	}{
+		{animatedGIF, 0, "loop", "0"},
+		{animatedGIF, 1, "loop", "-1"},
+		{animatedGIF, 2, "loop", "1"},
+		{animatedGIF, 5, "loop", "4"},
+		{animatedWebP, 0, "loop", "0"},
+		{animatedWebP, 1, "loop", "1"},
+		{animatedWebP, 3, "loop", "3"},
+		{animatedAPNG, 0, "plays", "0"},
+		{animatedAPNG, 1, "plays", "1"},
+		{animatedAPNG, 3, "plays", "3"},
+	}
+	for _, tt := range tests {
+		opts := AnimatedOptions{Format: tt.format, FPS: 15, Plays: tt.plays, Playback: PlaybackOptions{Speed: 1}}
+		args := animatedArgs(opts, animatedAttempt{FPS: 15, Width: 320, Quality: 80})
+		if got := args[tt.key]; got != tt.want {
+			t.Errorf("%s with %d plays: %s = %v, want %s", animatedFormatNames[tt.format], tt.plays, tt.key, got, tt.want)
+		}
+	}
+}
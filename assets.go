@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ffmpeg "github.com/Gordon-T/ffmpeg-go"
+)
+
+// Size and format limits of a Discord asset type
+type AssetSpec struct {
+	Name     string
+	Suffix   string // appended to the output file name before the extension
+	Width    int
+	Height   int
+	MaxBytes int64 // Discord's limits are binary, 256 KiB is 262,144 bytes
+	Animated []int // animated image formats the asset can be, the first is the default
+}
+
+const kibibyte = 1024
+
+var assetSpecs = []AssetSpec{
+	{Name: "Emoji", Suffix: "_emoji", Width: 128, Height: 128, MaxBytes: 256 * kibibyte, Animated: []int{animatedGIF, animatedWebP}},
+	{Name: "Sticker", Suffix: "_sticker", Width: 320, Height: 320, MaxBytes: 512 * kibibyte, Animated: []int{animatedAPNG}},
+	{Name: "Avatar", Suffix: "_avatar", Width: 512, Height: 512, MaxBytes: 10 * kibibyte * kibibyte, Animated: []int{animatedGIF}},
+	{Name: "Server Banner", Suffix: "_banner", Width: 960, Height: 540, MaxBytes: 10 * kibibyte * kibibyte, Animated: []int{animatedGIF}},
+}
+
+var assetNames = func() []string {
+	var names []string
+	for _, a := range assetSpecs {
+		names = append(names, a.Name)
+	}
+	return names
+}()
+
+// Names of the animated formats the asset can be, for the format combo box
+func (a AssetSpec) animatedNames() []string {
+	var names []string
+	for _, f := range a.Animated {
+		names = append(names, animatedFormatNames[f])
+	}
+	return names
+}
+
+// One try at fitting the asset, tried from best looking to smallest
+type assetAttempt struct {
+	FPS     int // 0 for still images
+	Colors  int // 0 keeps full color
+	Quality int // WebP only
+}
+
+var assetAnimatedAttempts = []assetAttempt{{20, 256, 0}, {15, 256, 0}, {15, 128, 0}, {12, 128, 0}, {10, 128, 0}, {10, 64, 0}, {8, 64, 0}, {6, 32, 0}}
+var assetWebPAttempts = []assetAttempt{{20, 0, 80}, {15, 0, 80}, {15, 0, 65}, {12, 0, 65}, {10, 0, 50}, {10, 0, 35}, {8, 0, 35}, {6, 0, 25}}
+var assetStillAttempts = []assetAttempt{{0, 0, 0}, {0, 256, 0}, {0, 128, 0}, {0, 64, 0}, {0, 32, 0}}
+
+// Settings for making an asset, filled in from the Assets tab
+type AssetOptions struct {
+	Spec     AssetSpec
+	Animated bool
+	Format   int     // animated image format, one of Spec.Animated
+	Start    float64 // seconds into the source
+	Length   float64 // seconds, animated assets only
+}
+
+// Scales and crops the source to fill the asset size without stretching it
+func (a AssetSpec) fillFilter() string {
+	return fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=increase,crop=%d:%d", a.Width, a.Height, a.Width, a.Height)
+}
+
+// Returns the ffmpeg arguments for one attempt at the asset
+func assetArgs(opts AssetOptions, attempt assetAttempt) ffmpeg.KwArgs {
+	filter := opts.Spec.fillFilter()
+	if attempt.FPS > 0 {
+		filter = fmt.Sprintf("fps=%d,", attempt.FPS) + filter
+	}
+	if attempt.Colors > 0 {
+		// diff spends the palette on what moves, full is better for a single frame
+		statsMode := "full"
+		if attempt.FPS > 0 {
+			statsMode = "diff"
+		}
+		filter += fmt.Sprintf(",split[a][b];[a]palettegen=max_colors=%d:stats_mode=%s[p];[b][p]paletteuse=dither=bayer:bayer_scale=5", attempt.Colors, statsMode)
+	}
+	ffmpegArguments := ffmpeg.KwArgs{"filter_complex": filter}
+	if !opts.Animated {
+		ffmpegArguments["frames:v"] = "1"
+		return ffmpegArguments
+	}
+	ffmpegArguments["t"] = strconv.FormatFloat(opts.Length, 'f', 3, 64)
+	switch opts.Format {
+	case animatedWebP:
+		ffmpegArguments["c:v"] = animatedEncoders[animatedWebP]
+		ffmpegArguments["quality"] = strconv.Itoa(attempt.Quality)
+		ffmpegArguments["compression_level"] = "6"
+		ffmpegArguments["loop"] = "0"
+	case animatedAPNG:
+		ffmpegArguments["f"] = "apng"
+		ffmpegArguments["plays"] = "0"
+	default:
+		ffmpegArguments["loop"] = "0"
+	}
+	return ffmpegArguments
+}
+
+func (a assetAttempt) String() string {
+	var parts []string
+	if a.FPS > 0 {
+		parts = append(parts, fmt.Sprintf("%d fps", a.FPS))
+	}
+	if a.Quality > 0 {
+		parts = append(parts, fmt.Sprintf("quality %d", a.Quality))
+	} else if a.Colors > 0 {
+		parts = append(parts, fmt.Sprintf("%d colors", a.Colors))
+	} else {
+		parts = append(parts, "full color")
+	}
+	return strings.Join(parts, " with ")
+}
+
+// Makes the asset, lowering the frame rate and colors, or quality for WebP, until it fits the byte limit.
+// Returns the output path, or "" if encoding failed, and a note on what it took to fit
+func assetEncode(filePath string, opts AssetOptions) (string, string) {
+	var fileName string = filepath.Base(filePath)
+	ext := ".png"
+	if opts.Animated && opts.Format == animatedGIF {
+		ext = ".gif"
+	} else if opts.Animated && opts.Format == animatedWebP {
+		ext = ".webp"
+	}
+	var outputName = filepath.Dir(filePath) + `\` + strings.TrimSuffix(fileName, filepath.Ext(fileName)) + opts.Spec.Suffix + ext
+
+	attempts := assetStillAttempts
+	if opts.Animated && opts.Format == animatedWebP {
+		attempts = assetWebPAttempts
+	} else if opts.Animated {
+		attempts = assetAnimatedAttempts
+	}
+	inputArgs := ffmpeg.KwArgs{}
+	if opts.Start > 0 {
+		inputArgs["ss"] = strconv.FormatFloat(opts.Start, 'f', 3, 64)
+	}
+
+	for i, attempt := range attempts {
+		attemptStatus = fmt.Sprintf("Try %d of %d, %s", i+1, len(attempts), attempt)
+		assetErr := ffmpeg.Input(filePath, inputArgs).Output(outputName, assetArgs(opts, attempt)).OverWriteOutput().SetFfmpegPath("./ffmpeg.exe").ErrorToStdOut().Run()
+		if assetErr != nil {
+			encodeError = true
+			log.Printf("Error occurred while making %s: %v", opts.Spec.Name, assetErr)
+			return "", ""
+		}
+		info, err := os.Stat(outputName)
+		if err != nil {
+			log.Println("Error checking asset size:", err)
+			return "", ""
+		}
+		log.Printf("%s try %d: %v bytes", opts.Spec.Name, i+1, info.Size())
+		if info.Size() <= opts.Spec.MaxBytes {
+			return outputName, fmt.Sprintf("%s fit at %s after %d tries", opts.Spec.Name, attempt, i+1)
+		}
+	}
+	// Keep the smallest try so there is something to look at, verifyOutputSize warns about it
+	return outputName, fmt.Sprintf("%s is still too big at %s, try a shorter segment", opts.Spec.Name, attempts[len(attempts)-1])
+}
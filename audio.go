@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	ffmpeg "github.com/Gordon-T/ffmpeg-go"
+)
+
+// Audio codec options, indexes match the audio codec radio buttons and audioFormats
+const (
+	audioMP3 = iota
+	audioOpus
+	audioAAC
+	audioVorbis
+	audioFLAC
+	audioWAV
+)
+
+// How the audio converter picks the size of the output
+const (
+	audioRateBitrate = iota // constant or average bitrate typed by the user
+	audioRateQuality        // VBR quality level typed by the user
+	audioRateTarget         // bitrate worked out from a target file size
+)
+
+// An audio output format and the limits of its encoder
+type AudioFormat struct {
+	Name     string
+	Suffix   string   // appended to the output file name
+	Encoders []string // the first one the local ffmpeg has is used
+	Lossless bool
+
+	// Bitrate range in kb/s, below Acceptable most people will hear the compression
+	Min        float32
+	Max        float32
+	Acceptable float32
+
+	// VBR quality range, zero if the format only takes a bitrate
+	QualityMin     float32
+	QualityMax     float32
+	QualityDefault float32
+}
+
+var audioFormats = []AudioFormat{
+	audioMP3:    {Name: "MP3", Suffix: "_mp3.mp3", Encoders: []string{"libmp3lame"}, Min: 8, Max: 320, Acceptable: 64, QualityMin: 0, QualityMax: 9, QualityDefault: 2},
+	audioOpus:   {Name: "Opus", Suffix: "_opus.opus", Encoders: []string{"libopus"}, Min: 6, Max: 510, Acceptable: 24},
+	audioAAC:    {Name: "AAC", Suffix: "_aac.m4a", Encoders: []string{"libfdk_aac", "aac"}, Min: 16, Max: 512, Acceptable: 64, QualityMin: 1, QualityMax: 5, QualityDefault: 4},
+	audioVorbis: {Name: "Vorbis", Suffix: "_vorbis.ogg", Encoders: []string{"libvorbis"}, Min: 48, Max: 480, Acceptable: 64, QualityMin: -1, QualityMax: 10, QualityDefault: 5},
+	audioFLAC:   {Name: "FLAC", Suffix: "_flac.flac", Encoders: []string{"flac"}, Lossless: true},
+	audioWAV:    {Name: "WAV", Suffix: "_wav.wav", Encoders: []string{"pcm_s16le"}, Lossless: true},
+}
+
+// libmp3lame only encodes these constant bitrates
+var mp3Bitrates = []float32{8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+
+// Settings for a single audio encode, filled in from the GUI by beginAudioConvert
+type AudioOptions struct {
+	Codec            int
+	Encoder          string
+	RateMode         int
+	Bitrate          float32 // kb/s
+	Quality          float32
+	CompressionLevel int // FLAC only
+	Filters          []string
+	Tracks           []AudioTrack
+	Metadata         int
+	Tags             map[string]string
+	CoverArt         int // index among the video streams, -1 if the source has none
+	Duration         float64
+}
+
+// Returns the first encoder for the format that the local ffmpeg build has, or "" if there is none
+func (f AudioFormat) encoder() string {
+	for _, e := range f.Encoders {
+		if ffmpegHasEncoder(e) {
+			return e
+		}
+	}
+	return ""
+}
+
+// Checks if the format can be encoded with a VBR quality level using the given encoder.
+// The native AAC encoder's VBR mode is experimental so only libfdk_aac is allowed
+func (f AudioFormat) hasQuality(encoder string) bool {
+	if f.QualityMax == f.QualityMin {
+		return false
+	}
+	return f.Name != "AAC" || encoder == "libfdk_aac"
+}
+
+// Returns the ffmpeg arguments for the audio options
+func audioCodecArgs(opts AudioOptions) ffmpeg.KwArgs {
+	ffmpegArguments := ffmpeg.KwArgs{
+		"vn":  "",
+		"c:a": opts.Encoder,
+	}
+	strBitrate := strconv.FormatFloat(float64(opts.Bitrate), 'f', -1, 64) + "k"
+	strQuality := strconv.FormatFloat(float64(opts.Quality), 'f', -1, 64)
+	addArgs(ffmpegArguments, audioTrackArgs(opts.Tracks, opts.Filters))
+
+	switch opts.Codec {
+	case audioFLAC:
+		ffmpegArguments["compression_level"] = strconv.Itoa(opts.CompressionLevel)
+	case audioWAV:
+		// Uncompressed, nothing to set
+	case audioAAC:
+		ffmpegArguments["movflags"] = "+faststart"
+		if opts.RateMode == audioRateQuality {
+			ffmpegArguments["vbr"] = strQuality // libfdk_aac VBR mode 1-5
+		} else {
+			ffmpegArguments["b:a"] = strBitrate
+		}
+	default:
+		if opts.RateMode == audioRateQuality {
+			ffmpegArguments["q:a"] = strQuality
+		} else {
+			ffmpegArguments["b:a"] = strBitrate
+		}
+	}
+
+	// Keep the tags and cover art of music according to the metadata policy
+	if opts.Codec == audioOpus || opts.Codec == audioVorbis {
+		addArgs(ffmpegArguments, oggMetadataArgs(opts.Metadata, opts.Tags))
+	} else {
+		addArgs(ffmpegArguments, metadataArgs(opts.Metadata, opts.Tags))
+	}
+	if opts.CoverArt >= 0 && keepsCoverArt(opts.Metadata, audioFormats[opts.Codec].Suffix) {
+		applyCoverArt(ffmpegArguments, opts.CoverArt)
+	}
+	return ffmpegArguments
+}
+
+// Checks the user's quality level against the range the format accepts
+func (f AudioFormat) validateQuality(quality float32) error {
+	if quality < f.QualityMin || quality > f.QualityMax {
+		return fmt.Errorf("%s quality must be between %v and %v", f.Name, f.QualityMin, f.QualityMax)
+	}
+	return nil
+}
+
+// Picks the audio bitrate that fits the file in targetBytes, clamped to what the codec can encode.
+// Returns the bitrate and warnings about the quality or size of the result
+func calculateAudioTarget(targetBytes int64, duration float64, codecType int) (float32, []string) {
+	var warnings []string
+	limits := audioFormats[codecType]
+	bitrate := calculateTarget(targetBytes, float32(duration), true)
+
+	if bitrate > limits.Max {
+		bitrate = limits.Max
+	}
+	if bitrate < limits.Min {
+		bitrate = limits.Min
+		warnings = append(warnings, fmt.Sprintf("Even the lowest %s bitrate (%v Kb/s) won't fit the target size", limits.Name, limits.Min))
+	}
+
+	// Round down to the closest bitrate MP3 supports so the file doesn't grow past the target
+	if codecType == audioMP3 {
+		closest := mp3Bitrates[0]
+		for _, b := range mp3Bitrates {
+			if b <= bitrate {
+				closest = b
+			}
+		}
+		bitrate = closest
+	} else {
+		bitrate = float32(int(bitrate))
+	}
+
+	if bitrate < limits.Acceptable {
+		suggestion := "try Opus or a shorter file"
+		if codecType == audioOpus {
+			suggestion = "try a shorter file or a bigger target size"
+		}
+		warnings = append(warnings, fmt.Sprintf("%v Kb/s is too low for %s to sound good, %s", bitrate, limits.Name, suggestion))
+	}
+	return bitrate, warnings
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ffmpeg "github.com/Gordon-T/ffmpeg-go"
+)
+
+// Visuals for audio to video, indexes match audioVisualNames
+const (
+	visualCoverArt = iota
+	visualImage
+	visualWaveform
+	visualSpectrum
+)
+
+var audioVisualNames = []string{"Cover Art", "Image", "Waveform", "Spectrum"}
+
+const (
+	stillImageBitrate      = 16  // kb/s, a still image at 1 fps barely costs anything
+	visualizationMinRate   = 48  // kb/s
+	visualizationMaxRate   = 250 // kb/s
+	audioVideoAudioMax     = 320 // kb/s, AAC doesn't get better past this
+	audioVideoAudioMinimum = 48  // kb/s, below this music sounds bad in AAC
+)
+
+// Settings for turning an audio file into a video
+type AudioVideoOptions struct {
+	Visual       int
+	ImagePath    string // for visualImage
+	CoverArt     int    // index among the video streams of the cover art, for visualCoverArt
+	VideoBitrate int    // kb/s
+	AudioBitrate int    // kb/s
+	Duration     float64
+	Tracks       []AudioTrack
+	Filters      []string
+	Metadata     int
+	Tags         map[string]string
+}
+
+// Splits the budget so nearly all of it goes to the audio. A still image only needs a
+// few kb/s, visualizations get a small share since they move. Returns the video and audio
+// bitrates and a warning if the audio ends up too low
+func audioVideoBudget(totalBitrate float32, visual int) (int, int, string) {
+	video := float32(stillImageBitrate)
+	if visual == visualWaveform || visual == visualSpectrum {
+		video = min(max(totalBitrate*0.15, visualizationMinRate), visualizationMaxRate)
+	}
+	audio := int(min(totalBitrate-video, audioVideoAudioMax))
+	if audio < audioVideoAudioMinimum {
+		return int(video), max(audio, 8), fmt.Sprintf("Only %d Kb/s is left for the audio, try a larger target size or a still image", max(audio, 8))
+	}
+	return int(video), audio, ""
+}
+
+// Returns the filter chain that makes the video from the audio, ending in the [v] label
+func audioVisualFilter(opts AudioVideoOptions) string {
+	// Still images are repeated once a second, that's enough for a picture that doesn't change
+	still := "loop=loop=-1:size=1:start=0,setpts=N/TB,scale=1280:720:force_original_aspect_ratio=decrease,scale=trunc(iw/2)*2:trunc(ih/2)*2,format=yuv420p[v]"
+	switch opts.Visual {
+	case visualCoverArt:
+		return fmt.Sprintf("[0:v:%d]", opts.CoverArt) + still
+	case visualImage:
+		return "movie=" + filterPath(opts.ImagePath) + "," + still
+	case visualSpectrum:
+		return "[vis]showspectrum=s=854x480:slide=scroll:color=intensity,fps=15,format=yuv420p[v]"
+	default:
+		return "[vis]showwaves=s=854x480:mode=cline:rate=15:colors=0x5865F2,format=yuv420p[v]"
+	}
+}
+
+// Returns the filter graph for the audio of the video, ending in [aout]. Waveforms and spectrums
+// are drawn from a copy in [vis] so they show the same mixed and filtered audio that is encoded
+func audioVideoAudioGraph(opts AudioVideoOptions) string {
+	end := "[aout]"
+	if opts.Visual == visualWaveform || opts.Visual == visualSpectrum {
+		end = ",asplit[aout][vis]"
+	}
+	audioArgs := audioTrackArgs(opts.Tracks, opts.Filters)
+	if audioGraph, ok := audioArgs["filter_complex"].(string); ok {
+		return strings.TrimSuffix(audioGraph, "[aout]") + end
+	}
+	input := "[0:a:0]"
+	if audioMap, ok := audioArgs["map"].(string); ok {
+		input = "[" + audioMap + "]"
+	}
+	chain := "anull"
+	if af, ok := audioArgs["af"].(string); ok {
+		chain = af
+	}
+	return input + chain + end
+}
+
+// Encodes an MP4 with the audio and a still image or visualization, returns the output path or "" if encoding failed
+func audioToVideoEncode(filePath string, opts AudioVideoOptions) string {
+	var fileName string = filepath.Base(filePath)
+	var outputName = filepath.Dir(filePath) + `\` + strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "_video.mp4"
+	videoBitrate, audioBitrate := opts.VideoBitrate, opts.AudioBitrate
+
+	ffmpegArguments := ffmpeg.KwArgs{
+		"c:v":      "libx264",
+		"preset":   "slow",
+		"b:v":      strconv.Itoa(videoBitrate) + "k",
+		"maxrate":  strconv.Itoa(videoBitrate*2) + "k",
+		"bufsize":  strconv.Itoa(videoBitrate*4) + "k",
+		"c:a":      audioFormats[audioAAC].encoder(),
+		"b:a":      strconv.Itoa(audioBitrate) + "k",
+		"t":        strconv.FormatFloat(opts.Duration, 'f', 3, 64),
+		"movflags": "+faststart",
+	}
+	if opts.Visual == visualCoverArt || opts.Visual == visualImage {
+		ffmpegArguments["tune"] = "stillimage"
+		ffmpegArguments["r"] = "1"
+	}
+
+	// The visual goes in the same graph as the audio so both can be mapped
+	graph := []string{audioVisualFilter(opts), audioVideoAudioGraph(opts)}
+	ffmpegArguments["filter_complex"] = strings.Join(graph, ";")
+	ffmpegArguments["map"] = []string{"[v]", "[aout]"}
+	addArgs(ffmpegArguments, metadataArgs(opts.Metadata, opts.Tags))
+
+	audioVideoErr := ffmpeg.Input(filePath).Output(outputName, ffmpegArguments).GlobalArgs("-progress", TempTCPProgress(opts.Duration)).OverWriteOutput().SetFfmpegPath("./ffmpeg.exe").ErrorToStdOut().Run()
+
+	if audioVideoErr != nil {
+		encodeError = true
+		log.Printf("Error occurred while encoding audio to video: %v", audioVideoErr)
+		return ""
+	}
+	log.Println("Encoded audio to video!")
+	return outputName
+}
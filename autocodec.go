@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ffmpeg "github.com/Gordon-T/ffmpeg-go"
+)
+
+// Auto codec selection encodes a few short samples with every usable codec and keeps the best scoring one
+const autoSampleCount = 3
+const autoSampleLength = 4.0
+
+type AutoCodecChoice struct {
+	Codec  string             `json:"codec"`
+	Metric string             `json:"metric"`
+	Scores map[string]float64 `json:"scores"`
+}
+
+// Codecs that auto selection is allowed to pick from, in order of compatibility
+func autoCodecCandidates(platform PlatformProfile) []int {
+	var candidates []int
+	if platform.allowsCodec(codecH264) {
+		candidates = append(candidates, codecH264)
+	}
+	if !requireIOSPlayback && platform.allowsCodec(codecVP9) && ffmpegHasEncoder("libvpx-vp9") {
+		candidates = append(candidates, codecVP9)
+	}
+	if platform.allowsCodec(codecHEVC) && ffmpegHasEncoder("libx265") {
+		candidates = append(candidates, codecHEVC)
+	}
+	// Only recent iPhones can decode AV1
+	if !requireIOSPlayback && platform.allowsCodec(codecAV1) && ffmpegHasEncoder("libaom-av1") {
+		candidates = append(candidates, codecAV1)
+	}
+	if len(candidates) == 0 {
+		candidates = append(candidates, codecH264)
+	}
+	return candidates
+}
+
+// Returns the start times of the samples spread across the clip
+func autoSampleStarts(duration float64) ([]float64, float64) {
+	if duration <= autoSampleCount*autoSampleLength {
+		return []float64{0}, duration
+	}
+	var starts []float64
+	for i := 1; i <= autoSampleCount; i++ {
+		starts = append(starts, duration*float64(i)/(autoSampleCount+1)-autoSampleLength/2)
+	}
+	return starts, autoSampleLength
+}
+
+// Trial encodes samples with each candidate codec at the target bitrate and returns the best one.
+// Ties go to the more compatible codec since candidates are ordered by compatibility.
+// The samples are spread over the source and get the speed change, so does the reference they're scored against
+func pickAutoCodec(filePath string, opts VideoOptions, playback PlaybackOptions, platform PlatformProfile, width int, height int, refFilters []string) (int, *AutoCodecChoice) {
+	strBitrate := strconv.FormatFloat(float64(opts.Bitrate), 'f', -1, 64)
+	starts, length := autoSampleStarts(playback.sourceDuration(opts.Duration))
+	strLength := strconv.FormatFloat(length, 'f', 3, 64)
+	sampleFilters := append(append([]string{}, opts.Filters...), playback.videoFilters(length)...)
+	refFilters = append(append([]string{}, refFilters...), playback.videoFilters(length)...)
+	candidates := autoCodecCandidates(platform)
+
+	choice := &AutoCodecChoice{Scores: map[string]float64{}}
+	bestCodec := candidates[0]
+	bestScore := -1.0
+	for _, codec := range candidates {
+		total := 0.0
+		scored := 0
+		for i, start := range starts {
+			autoCodecStatus = fmt.Sprintf("Testing %s (sample %d/%d)", videoCodecNames[codec], i+1, len(starts))
+			strStart := strconv.FormatFloat(start, 'f', 3, 64)
+
+			codecArgs, suffix := videoCodecArgs(codec, strBitrate, 0)
+			if len(sampleFilters) > 0 {
+				codecArgs["vf"] = strings.Join(sampleFilters, ",")
+			}
+			samplePath := filepath.Join(os.TempDir(), "dmt_sample"+suffix)
+			args := []string{"-hide_banner", "-ss", strStart, "-t", strLength, "-i", filePath}
+			args = append(args, ffmpeg.ConvertKwargsToCmdLineArgs(codecArgs)...)
+			args = append(args, "-an", "-y", samplePath)
+			_, err := runFFmpegLog(args...)
+			if err != nil {
+				log.Printf("Error encoding %s sample: %v", videoCodecNames[codec], err)
+				break
+			}
+
+			score := measureQuality([]string{
+				"-i", samplePath,
+				"-ss", strStart, "-t", strLength, "-i", filePath,
+			}, metricVMAF, width, height, playback.outputDuration(length), refFilters)
+			os.Remove(samplePath)
+			if score == nil {
+				break
+			}
+			choice.Metric = score.Metric
+			total += score.Score
+			scored++
+		}
+		if scored != len(starts) {
+			log.Printf("Skipping %s for auto codec selection", videoCodecNames[codec])
+			continue
+		}
+
+		average := total / float64(scored)
+		choice.Scores[videoCodecNames[codec]] = average
+		log.Printf("auto codec: %s scored %.3f", videoCodecNames[codec], average)
+		if average > bestScore {
+			bestScore = average
+			bestCodec = codec
+		}
+	}
+	autoCodecStatus = ""
+
+	choice.Codec = videoCodecNames[bestCodec]
+	return bestCodec, choice
+}
+
+func (c AutoCodecChoice) String() string {
+	s := "Auto picked " + c.Codec
+	if score, ok := c.Scores[c.Codec]; ok {
+		s += fmt.Sprintf(" (%s %.2f)", c.Metric, score)
+	}
+	return s
+}
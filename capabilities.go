@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// "-filters" and "-encoders" listings from the local ffmpeg build, read once by probeFFmpeg
+var ffmpegListings sync.Once
+var ffmpegFilterList string
+var ffmpegEncoderList string
+
+// What the layout shows depending on the build. Set by dependencyCheck once ffmpegProbed is true
+// so drawing a frame never has to wait on ffmpeg
+var ffmpegCapabilities sync.Once
+var ffmpegProbed bool
+var videoCodecAvailable = make([]bool, len(videoCodecEncoders)) // indexes match videoCodecEncoders
+var audioFormatEncoders = make([]string, len(audioFormats))     // indexes match audioFormats
+
+// Lists the filters and encoders of the local ffmpeg build. Safe to call from any goroutine,
+// only the first call runs ffmpeg
+func probeFFmpeg() {
+	ffmpegListings.Do(func() {
+		ffmpegFilterList = ffmpegListing("-filters")
+		ffmpegEncoderList = ffmpegListing("-encoders")
+	})
+}
+
+// Checks if the local ffmpeg build was compiled with the given filter
+func ffmpegHasFilter(name string) bool {
+	probeFFmpeg()
+	return listingContains(ffmpegFilterList, name)
+}
+
+// Checks if the local ffmpeg build was compiled with the given encoder
+func ffmpegHasEncoder(name string) bool {
+	probeFFmpeg()
+	return listingContains(ffmpegEncoderList, name)
+}
+
+// Looks up the encoders the layout needs and marks the build as probed. dependencyCheck runs
+// again whenever a button starts an encode, only the first call fills these in
+func cacheFFmpegCapabilities() {
+	ffmpegCapabilities.Do(func() {
+		for i, encoder := range videoCodecEncoders {
+			videoCodecAvailable[i] = ffmpegHasEncoder(encoder)
+		}
+		for i, format := range audioFormats {
+			audioFormatEncoders[i] = format.encoder()
+		}
+		ffmpegProbed = true
+	})
+}
+
+func ffmpegListing(flag string) string {
+	cmd := exec.Command("./ffmpeg.exe", "-hide_banner", flag)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	outputBytes, err := cmd.Output()
+	if err != nil {
+		log.Printf("Error listing ffmpeg %s: %v", flag, err)
+		return ""
+	}
+	return string(outputBytes)
+}
+
+// Listings are formatted as "<flags> <name> <description>" per line
+func listingContains(listing string, name string) bool {
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[1] == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Caption styles, indexes match captionStyleNames
+const (
+	captionOverlay = iota // white text with a black outline on top of the video
+	captionBar            // black text in a white bar added above the video
+)
+
+var captionStyleNames = []string{"Overlay", "Caption Bar"}
+
+// Fonts that come with Windows, indexes match captionFontNames
+var captionFontNames = []string{"Impact", "Arial Bold", "Arial", "Comic Sans", "Times New Roman"}
+var captionFontFiles = []string{"impact.ttf", "arialbd.ttf", "arial.ttf", "comic.ttf", "times.ttf"}
+
+const captionFontDir = "C:/Windows/Fonts/"
+
+// Text drawn on a video or animated image
+type CaptionOptions struct {
+	TopText    string
+	BottomText string
+	Style      int
+	Font       int
+	Size       int // font size in percent of the video height
+	Outline    int // outline width in pixels, overlay style only
+	Start      float64
+	End        float64 // 0 shows the text until the end
+}
+
+func (c CaptionOptions) enabled() bool {
+	return strings.TrimSpace(c.TopText) != "" || strings.TrimSpace(c.BottomText) != ""
+}
+
+// Checks the caption settings and that the font exists
+func (c CaptionOptions) validate() error {
+	if !c.enabled() {
+		return nil
+	}
+	if c.Size <= 0 || c.Size > 50 {
+		return errors.New("caption size must be between 1 and 50 percent")
+	}
+	if c.End > 0 && c.End <= c.Start {
+		return errors.New("caption end time must be after the start time")
+	}
+	if _, err := os.Stat(c.fontFile()); err != nil {
+		return errors.New("font " + captionFontNames[c.Font] + " isn't installed")
+	}
+	return nil
+}
+
+func (c CaptionOptions) fontFile() string {
+	return captionFontDir + captionFontFiles[c.Font]
+}
+
+func (c CaptionOptions) fontPixels(videoHeight int) int {
+	return max(videoHeight*c.Size/100, 8)
+}
+
+// Height of the white bar added above the video, 0 for the overlay style
+func (c CaptionOptions) barHeight(videoHeight int) int {
+	if !c.enabled() || c.Style != captionBar || strings.TrimSpace(c.TopText) == "" {
+		return 0
+	}
+	return evenSize(float64(c.fontPixels(videoHeight)) * 1.8)
+}
+
+// Escapes text for use as a filter option inside a filter graph
+func filterText(text string) string {
+	optionEscaper := strings.NewReplacer(`\`, `\\`, "'", `\'`, ":", `\:`)
+	graphEscaper := strings.NewReplacer(`\`, `\\`, "'", `\'`, "[", `\[`, "]", `\]`, ",", `\,`, ";", `\;`)
+	return graphEscaper.Replace(optionEscaper.Replace(text))
+}
+
+// Builds one drawtext filter. y is an expression for the top of the text
+func (c CaptionOptions) drawText(text string, fontSize int, color string, outline int, y string) string {
+	filter := fmt.Sprintf("drawtext=fontfile=%s:text=%s:expansion=none:fontsize=%d:fontcolor=%s:x=(w-text_w)/2:y=%s",
+		filterPath(c.fontFile()), filterText(text), fontSize, color, y)
+	if outline > 0 {
+		filter += fmt.Sprintf(":borderw=%d:bordercolor=black", outline)
+	}
+	if c.Start > 0 || c.End > 0 {
+		end := "1e9"
+		if c.End > 0 {
+			end = fmt.Sprintf("%.3f", c.End)
+		}
+		filter += fmt.Sprintf(`:enable=between(t\,%.3f\,%s)`, c.Start, end)
+	}
+	return filter
+}
+
+// Returns the filters that draw the caption on a video of the given size. These go after any
+// scaling so the text is drawn at the output resolution
+func (c CaptionOptions) filters(width int, height int) []string {
+	if !c.enabled() {
+		return nil
+	}
+	var filters []string
+	fontSize := c.fontPixels(height)
+	margin := max(height/25, 4)
+	top := strings.TrimSpace(c.TopText)
+	bottom := strings.TrimSpace(c.BottomText)
+
+	if bar := c.barHeight(height); bar > 0 {
+		filters = append(filters, fmt.Sprintf("pad=iw:ih+%d:0:%d:color=white", bar, bar))
+		filters = append(filters, c.drawText(top, fontSize, "black", 0, fmt.Sprintf("(%d-text_h)/2", bar)))
+	} else if top != "" {
+		filters = append(filters, c.drawText(top, fontSize, "white", c.Outline, fmt.Sprintf("%d", margin)))
+	}
+	if bottom != "" {
+		filters = append(filters, c.drawText(bottom, fontSize, "white", c.Outline, fmt.Sprintf("h-text_h-%d", margin)))
+	}
+	return filters
+}
+
+// Makes room for the caption bar in the plan. The bar adds to the output height, so when the
+// platform limits the resolution the video is shrunk until the video and bar fit together
+func (plan *EncodePlan) fitCaption(p PlatformProfile, c CaptionOptions) {
+	bar := c.barHeight(plan.Height)
+	if bar == 0 {
+		return
+	}
+	width, height := fitInBox(plan.Width, plan.Height+bar, p.MaxWidth, p.MaxHeight)
+	if height != plan.Height+bar {
+		scale := float64(height) / float64(plan.Height+bar)
+		plan.Width = evenSize(float64(plan.Width) * scale)
+		plan.Height = evenSize(float64(plan.Height) * scale)
+		bar = c.barHeight(plan.Height)
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("Downscaled to %dx%d so the caption bar fits the %s limit", width, height, p.Name))
+	}
+	plan.PadHeight = bar
+}
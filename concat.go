@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ffmpeg "github.com/Gordon-T/ffmpeg-go"
+)
+
+const (
+	concatSampleRate = 48000
+	concatMaxFPS     = 60
+	concatDefaultFPS = 30
+)
+
+// A video in the list of clips to join
+type ConcatClip struct {
+	Path      string
+	Duration  float64
+	Width     int // the way it is played
+	Height    int
+	Rotation  int // degrees clockwise, the movie filter doesn't rotate by the metadata
+	FrameRate float64
+	HasAudio  bool
+}
+
+func (c ConcatClip) label() string {
+	return fmt.Sprintf("%s (%.1fs, %dx%d)", filepath.Base(c.Path), c.Duration, c.Width, c.Height)
+}
+
+// Probes a video for the clip list
+func loadConcatClip(path string) (ConcatClip, error) {
+	info, err := ffmpeg.Probe(path)
+	if err != nil {
+		return ConcatClip{}, errors.New("couldn't read " + filepath.Base(path))
+	}
+	var mediaInfo MediaInfo
+	err = json.Unmarshal([]byte(info), &mediaInfo)
+	if err != nil {
+		return ConcatClip{}, errors.New("couldn't read " + filepath.Base(path))
+	}
+	clip := ConcatClip{Path: path, Rotation: mediaInfo.rotation(), FrameRate: mediaInfo.frameRate(), HasAudio: mediaInfo.hasAudio()}
+	clip.Width, clip.Height = mediaInfo.displaySize()
+	clip.Duration, err = strconv.ParseFloat(mediaInfo.Format.Duration, 64)
+	if err != nil || clip.Width == 0 || clip.Duration <= 0 {
+		return ConcatClip{}, errors.New(filepath.Base(path) + " isn't a video")
+	}
+	return clip, nil
+}
+
+// Clips joined into one video, every clip is brought to the same size, frame rate and sample rate
+type ConcatOptions struct {
+	Clips     []ConcatClip
+	Width     int
+	Height    int
+	FPS       float64
+	Crossfade float64 // seconds each clip fades into the next, 0 cuts straight to it
+}
+
+// Returns the highest frame rate of the clips so none of them gets choppier, capped at 60
+func concatFrameRate(clips []ConcatClip) float64 {
+	fps := 0.0
+	for _, c := range clips {
+		fps = max(fps, c.FrameRate)
+	}
+	if fps <= 0 {
+		return concatDefaultFPS
+	}
+	return min(fps, concatMaxFPS)
+}
+
+// Returns the length of the joined video, crossfades overlap the clips
+func (c ConcatOptions) duration() float64 {
+	total := 0.0
+	for _, clip := range c.Clips {
+		total += clip.Duration
+	}
+	if len(c.Clips) > 1 {
+		total -= float64(len(c.Clips)-1) * c.Crossfade
+	}
+	return total
+}
+
+func (c ConcatOptions) check() error {
+	if len(c.Clips) < 2 {
+		return errors.New("add at least two clips to combine")
+	}
+	for _, clip := range c.Clips {
+		if c.Crossfade*2 >= clip.Duration {
+			return fmt.Errorf("the crossfade must be shorter than half of %s", filepath.Base(clip.Path))
+		}
+	}
+	return nil
+}
+
+// Returns the start of the filter chains that read a clip. The first clip is the input of the
+// encode, the rest are read with movie and amovie like added audio. Clips without audio get silence
+func (c ConcatOptions) clipInputs(i int) (string, string) {
+	clip := c.Clips[i]
+	video, audio := "[0:v:0]", "[0:a:0]"
+	if i > 0 {
+		video = "movie=" + filterPath(clip.Path) + ","
+		turn := VideoTransform{Rotation: clip.Rotation / 90}.filters()
+		if len(turn) > 0 {
+			video += strings.Join(turn, ",") + ","
+		}
+		audio = "amovie=" + filterPath(clip.Path) + ","
+	}
+	if !clip.HasAudio {
+		audio = fmt.Sprintf("anullsrc=r=%d:cl=stereo,atrim=duration=%.3f,", concatSampleRate, clip.Duration)
+	}
+	return video, audio
+}
+
+// Returns the filter graph that brings the clips to a common format and joins them, with the video
+// in [vout] and the audio in [aout]. filters are applied to the joined video
+func (c ConcatOptions) graph(filters []string, withAudio bool) string {
+	var graph []string
+	for i := range c.Clips {
+		video, audio := c.clipInputs(i)
+		graph = append(graph, fmt.Sprintf("%sscale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%.3f,format=yuv420p,setpts=PTS-STARTPTS,settb=AVTB[v%d]",
+			video, c.Width, c.Height, c.Width, c.Height, c.FPS, i))
+		if withAudio {
+			graph = append(graph, fmt.Sprintf("%saresample=%d,aformat=sample_fmts=fltp:channel_layouts=stereo,asetpts=PTS-STARTPTS[a%d]", audio, concatSampleRate, i))
+		}
+	}
+
+	if c.Crossfade <= 0 {
+		var inputs, audioOut string
+		audioCount := 0
+		for i := range c.Clips {
+			inputs += fmt.Sprintf("[v%d]", i)
+			if withAudio {
+				inputs += fmt.Sprintf("[a%d]", i)
+			}
+		}
+		if withAudio {
+			audioCount, audioOut = 1, "[aout]"
+		}
+		graph = append(graph, fmt.Sprintf("%sconcat=n=%d:v=1:a=%d[vjoin]%s", inputs, len(c.Clips), audioCount, audioOut))
+	} else {
+		// Each fade starts where the joined video so far ends, minus the fade
+		videoIn, audioIn := "[v0]", "[a0]"
+		offset := 0.0
+		for i := 1; i < len(c.Clips); i++ {
+			offset += c.Clips[i-1].Duration - c.Crossfade
+			videoOut, audioOut := fmt.Sprintf("[vx%d]", i), fmt.Sprintf("[ax%d]", i)
+			if i == len(c.Clips)-1 {
+				videoOut, audioOut = "[vjoin]", "[aout]"
+			}
+			graph = append(graph, fmt.Sprintf("%s[v%d]xfade=transition=fade:duration=%.3f:offset=%.3f%s", videoIn, i, c.Crossfade, offset, videoOut))
+			if withAudio {
+				graph = append(graph, fmt.Sprintf("%s[a%d]acrossfade=d=%.3f%s", audioIn, i, c.Crossfade, audioOut))
+			}
+			videoIn, audioIn = videoOut, audioOut
+		}
+	}
+	graph = append(graph, "[vjoin]"+strings.Join(append([]string{"null"}, filters...), ",")+"[vout]")
+	return strings.Join(graph, ";")
+}
+
+// Returns the ffmpeg arguments that join the clips, without audio for the first pass
+func (c ConcatOptions) args(filters []string, withAudio bool) ffmpeg.KwArgs {
+	maps := []string{"[vout]"}
+	if withAudio {
+		maps = append(maps, "[aout]")
+	}
+	return ffmpeg.KwArgs{
+		"filter_complex": c.graph(filters, withAudio),
+		"map":            maps,
+	}
+}
@@ -1,36 +1,139 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 
 	//"math/rand"
 	"net"
 	"os"
+	"os/exec"
 
 	//"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
 
 	//"time"
 
 	ffmpeg "github.com/Gordon-T/ffmpeg-go"
 )
 
+type MediaStream struct {
+	CodecType string `json:"codec_type"`
+	CodecName string `json:"codec_name"`
+	FrameRate string `json:"avg_frame_rate"`
+	Width     int
+	Height    int
+	Tags      struct {
+		Title    string
+		Language string
+		Rotate   string // older files store the rotation as a tag
+	} `json:"tags"`
+	SideDataList []struct {
+		Rotation float64 `json:"rotation"`
+	} `json:"side_data_list"`
+	Disposition struct {
+		AttachedPic int `json:"attached_pic"` // 1 for cover art in audio files
+	} `json:"disposition"`
+}
+
 type MediaInfo struct {
-	Streams []struct {
-		CodecType string `json:"codec_type"`
-		Width     int
-		Height    int
-	} `json:"streams"`
-	Format struct {
+	Streams []MediaStream `json:"streams"`
+	Format  struct {
 		Duration string
+		Tags     map[string]string `json:"tags"`
 	} `json:"format"`
 }
 
+// Returns the resolution of the first video stream, or 0x0 if there is none
+func (m MediaInfo) videoSize() (int, int) {
+	for _, s := range m.Streams {
+		if s.CodecType == "video" {
+			return s.Width, s.Height
+		}
+	}
+	return 0, 0
+}
+
+// Returns the average frame rate of the first video stream, or 0 if it isn't known
+func (m MediaInfo) frameRate() float64 {
+	for _, s := range m.Streams {
+		if s.CodecType == "video" {
+			return parseFrameRate(s.FrameRate)
+		}
+	}
+	return 0
+}
+
+// Returns how many degrees clockwise the first video stream is turned when played, 0, 90, 180 or 270.
+// Older files store it as a tag, newer ones as a display matrix that turns the other way
+func (m MediaInfo) rotation() int {
+	for _, s := range m.Streams {
+		if s.CodecType != "video" {
+			continue
+		}
+		rotation, _ := strconv.ParseFloat(s.Tags.Rotate, 64)
+		for _, side := range s.SideDataList {
+			if side.Rotation != 0 {
+				rotation = -side.Rotation
+			}
+		}
+		return (int(math.Round(rotation))%360 + 360) % 360
+	}
+	return 0
+}
+
+// Returns the resolution of the first video stream the way it is played. ffmpeg rotates
+// frames by the rotation metadata of phone videos, so 90 and 270 degrees swap the sides
+func (m MediaInfo) displaySize() (int, int) {
+	width, height := m.videoSize()
+	if m.rotation()%180 == 90 {
+		return height, width
+	}
+	return width, height
+}
+
+// Returns the index among the video streams of the cover art embedded in an audio file, or -1
+func (m MediaInfo) coverArt() int {
+	videoIndex := 0
+	for _, s := range m.Streams {
+		if s.CodecType != "video" {
+			continue
+		}
+		if s.Disposition.AttachedPic == 1 {
+			return videoIndex
+		}
+		videoIndex++
+	}
+	return -1
+}
+
+// Checks if the file is an animated GIF or WebP rather than a real video
+func (m MediaInfo) animatedImage() bool {
+	duration, _ := strconv.ParseFloat(m.Format.Duration, 64)
+	for _, s := range m.Streams {
+		if s.CodecType == "video" {
+			return (s.CodecName == "gif" || s.CodecName == "webp") && duration > 0
+		}
+	}
+	return false
+}
+
+func (m MediaInfo) hasAudio() bool {
+	for _, s := range m.Streams {
+		if s.CodecType == "audio" {
+			return true
+		}
+	}
+	return false
+}
+
 // Retrieves media information and returns it in a struct
 func getMediaInfo(fileName string, mediaType string) MediaInfo {
 	mediaInfo := &MediaInfo{}
@@ -62,79 +165,176 @@ func getMediaInfo(fileName string, mediaType string) MediaInfo {
 	return *mediaInfo
 }
 
-func videoEncode(filePath string, bitrate float32, codecType int, duration float64) {
-
-	// File directory shenanigans
-	var fileName string = filepath.Base(filePath)
-
-	// Bitrate shenanigans
-	var strMaxBitrate = strconv.FormatFloat(float64(bitrate), 'f', -1, 64)
+// Video codec options, indexes match the codec radio buttons and videoCodecNames
+const (
+	codecH264 = iota
+	codecVP9
+	codecHEVC
+	codecAV1
+	codecAuto
+)
 
-	var ffmpegArguments = ffmpeg.KwArgs{}
-	outputName := fileName
+var videoCodecNames = []string{"H264", "VP9", "HEVC", "AV1"}
+var videoCodecEncoders = []string{"libx264", "libvpx-vp9", "libx265", "libaom-av1"}
 
-	// Encode 1st pass
-	if codecType == 0 { //x264
+// Returns the ffmpeg video arguments and output file suffix for a codec.
+// pass is 1 or 2 for two-pass encodes and 0 for single pass encodes
+func videoCodecArgs(codecType int, strBitrate string, pass int) (ffmpeg.KwArgs, string) {
+	var ffmpegArguments ffmpeg.KwArgs
+	var suffix string
+	switch codecType {
+	case codecVP9:
 		ffmpegArguments = ffmpeg.KwArgs{
-			"c:v":      "libx264",
+			"c:v":      "libvpx-vp9",
+			"b:v":      strBitrate + "k",
+			"deadline": "good",
+		}
+		suffix = "_vp9.webm"
+	case codecHEVC:
+		ffmpegArguments = ffmpeg.KwArgs{
+			"c:v":      "libx265",
 			"preset":   "slow",
-			"b:v":      strMaxBitrate + "k",
+			"b:v":      strBitrate + "k",
+			"tag:v":    "hvc1", // needed for Apple devices to play it
 			"movflags": "+faststart",
-			"pass":     "1",
-			"c:a":      "libopus",
-			"b:a":      "96k",
-			"an":       "",
-			"f":        "null",
 		}
-		outputName = "./" + outputName + "_x264.mp4"
-	} else { //vp9
+		suffix = "_hevc.mp4"
+	case codecAV1:
 		ffmpegArguments = ffmpeg.KwArgs{
-			"c:v":      "libvpx-vp9",
-			"b:v":      strMaxBitrate + "k",
-			"deadline": "good",
-			"pass":     "1",
-			"an":       "",
-			"f":        "null",
+			"c:v":      "libaom-av1",
+			"b:v":      strBitrate + "k",
+			"cpu-used": "4",
+			"row-mt":   "1",
+			"movflags": "+faststart",
+		}
+		suffix = "_av1.mp4"
+	default:
+		ffmpegArguments = ffmpeg.KwArgs{
+			"c:v":      "libx264",
+			"preset":   "slow",
+			"b:v":      strBitrate + "k",
+			"movflags": "+faststart",
 		}
-		outputName = "./" + outputName + "_vp9.webm"
+		suffix = "_h264.mp4"
+	}
+
+	if pass > 0 {
+		if codecType == codecHEVC {
+			// libx265 ignores -pass and takes it through its own parameters instead
+			ffmpegArguments["x265-params"] = "pass=" + strconv.Itoa(pass) + ":stats=ffmpeg2pass-0.log"
+		} else {
+			ffmpegArguments["pass"] = strconv.Itoa(pass)
+		}
+	}
+	return ffmpegArguments, suffix
+}
+
+// Audio codec options inside video outputs, indexes match videoAudioCodecNames
+const (
+	videoAudioOpus = iota
+	videoAudioAAC
+	videoAudioNone
+)
+
+var videoAudioCodecNames = []string{"Opus", "AAC", "No Audio"}
+
+// Adds the audio codec, bitrate, channels and track selection to the ffmpeg arguments
+func applyVideoAudio(ffmpegArguments ffmpeg.KwArgs, opts VideoOptions) {
+	if opts.AudioCodec == videoAudioNone {
+		ffmpegArguments["an"] = ""
+		return
+	}
+	if opts.AudioCodec == videoAudioAAC {
+		ffmpegArguments["c:a"] = audioFormats[audioAAC].encoder()
+	} else {
+		ffmpegArguments["c:a"] = "libopus"
+	}
+	ffmpegArguments["b:a"] = strconv.Itoa(opts.AudioBitrate) + "k"
+	if opts.AudioMono {
+		ffmpegArguments["ac"] = "1"
+	}
+	if opts.Concat != nil {
+		addArgs(ffmpegArguments, opts.Concat.args(opts.Filters, true))
+		return
+	}
+	applyVideoAudioTracks(ffmpegArguments, opts.AudioTracks, opts.AudioFilters)
+}
+
+// Settings for a single video encode, filled in from the GUI by beginEncode
+type VideoOptions struct {
+	Codec        int
+	Bitrate      float32 // video bitrate in kb/s
+	AudioCodec   int
+	AudioBitrate int // kb/s
+	AudioMono    bool
+	Filters      []string
+	AudioFilters []string
+	AudioTracks  []AudioTrack
+	Metadata     int
+	Tags         map[string]string
+	Duration     float64 // seconds of output
+	TargetBytes  int64
+	Trim         bool // stop the output at Duration instead of the end of the source
+	KeepTiming   bool // pass frame timestamps through for variable frame delay sources like GIFs
+	Subtitles    SubtitleOptions
+	Concat       *ConcatOptions // joins a list of clips instead of encoding the input on its own
+}
+
+// Adds the video filter chain and trimming to the ffmpeg arguments
+func applyVideoOptions(ffmpegArguments ffmpeg.KwArgs, opts VideoOptions) {
+	if opts.Concat != nil {
+		addArgs(ffmpegArguments, opts.Concat.args(opts.Filters, false))
+	} else if overlay := opts.Subtitles.overlay(); overlay != "" {
+		// Bitmap subtitles are a second stream of the input so the filters need a graph instead of -vf
+		ffmpegArguments["filter_complex"] = strings.Join(append([]string{overlay}, opts.Filters...), ",") + "[vout]"
+		ffmpegArguments["map"] = []string{"[vout]"}
+	} else if len(opts.Filters) > 0 {
+		ffmpegArguments["vf"] = strings.Join(opts.Filters, ",")
+	}
+	if opts.Trim {
+		ffmpegArguments["t"] = strconv.FormatFloat(opts.Duration, 'f', 3, 64)
 	}
+	if opts.KeepTiming {
+		ffmpegArguments["fps_mode"] = "passthrough"
+	}
+}
+
+// Two-pass encodes the video and returns the output path, or "" if encoding failed
+func videoEncode(filePath string, opts VideoOptions) string {
+
+	// File directory shenanigans
+	var fileName string = filepath.Base(filePath)
+
+	// Bitrate shenanigans
+	var strMaxBitrate = strconv.FormatFloat(float64(opts.Bitrate), 'f', -1, 64)
+	duration := opts.Duration
+
+	// Encode 1st pass
+	ffmpegArguments, suffix := videoCodecArgs(opts.Codec, strMaxBitrate, 1)
+	applyVideoOptions(ffmpegArguments, opts)
+	ffmpegArguments["an"] = ""
+	ffmpegArguments["f"] = "null"
+	outputName := "./" + fileName + suffix
 
 	pass1Err := ffmpeg.Input(filePath).Output(outputName, ffmpegArguments).GlobalArgs("-progress", TempTCPProgress(duration)).OverWriteOutput().SetFfmpegPath("./ffmpeg.exe").ErrorToStdOut().Run()
 
 	if pass1Err != nil {
 		encodeError = true
 		encodingFirstPass = false
-		log.Println("Error occurred while performing 1st pass: %v", pass1Err)
-		return
+		log.Printf("Error occurred while performing 1st pass: %v", pass1Err)
+		return ""
 	}
 	encodingFirstPass = false
 	// Encode 2nd pass
-	outputName = filepath.Dir(filePath)
-
-	if codecType == 0 { // x264
-		ffmpegArguments = ffmpeg.KwArgs{
-			"c:v":      "libx264",
-			"preset":   "slow",
-			"b:v":      strMaxBitrate + "k",
-			"movflags": "+faststart",
-			"pass":     "2",
-			"c:a":      "libopus",
-			"b:a":      "96k",
-		}
-
-		outputName = outputName + `\` + strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "_h264.mp4"
-	} else { // vp9
-		ffmpegArguments = ffmpeg.KwArgs{
-			"c:v":      "libvpx-vp9",
-			"b:v":      strMaxBitrate + "k",
-			"deadline": "good",
-			"pass":     "2",
-			"c:a":      "libopus",
-			"b:a":      "96k",
-		}
-
-		outputName = outputName + `\` + strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "_vp9.webm"
+	ffmpegArguments, _ = videoCodecArgs(opts.Codec, strMaxBitrate, 2)
+	applyVideoOptions(ffmpegArguments, opts)
+	applyVideoAudio(ffmpegArguments, opts)
+	addArgs(ffmpegArguments, metadataArgs(opts.Metadata, opts.Tags))
+	baseName := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	if opts.Concat != nil {
+		baseName += "_combined"
 	}
+	outputName = filepath.Dir(filePath) + `\` + baseName + suffix
 
 	// Needs reworking
 	/*
@@ -144,15 +344,9 @@ func videoEncode(filePath string, bitrate float32, codecType int, duration float
 		The size of the output file is slightly more than the requested file size.
 	*/
 	if fsArgument {
-		fSize, err := strconv.ParseFloat(strTargetSize, 32)
-		if err != nil {
-			encodeError = true
-			log.Printf("Error occurred while parsing target file size: %v", err)
-			return
-		}
-		ffmpegArguments["fs"] = int((fSize * 1048576) * 0.99)
-		log.Printf("fs size = %v", int((fSize*1048576)*0.99))
-		log.Printf("raw size = %v", fSize)
+		ffmpegArguments["fs"] = int(float64(opts.TargetBytes) * 0.99)
+		log.Printf("fs size = %v", int(float64(opts.TargetBytes)*0.99))
+		log.Printf("raw size = %v", opts.TargetBytes)
 		log.Printf("%v", ffmpegArguments)
 	}
 	log.Println(outputName)
@@ -162,83 +356,46 @@ func videoEncode(filePath string, bitrate float32, codecType int, duration float
 	if pass2Err != nil {
 		encodeError = true
 		log.Printf("Error occurred while performing 2nd pass: %v", pass2Err)
-		return
+		return ""
 	} else {
 		log.Println("2nd pass done!")
 
-		// Remove 2 pass log files
-		err := os.Remove("./ffmpeg2pass-0.log")
-		if err != nil {
-			log.Println("Error removing 2-pass log files: %v\n", err)
-		}
-		err = os.Remove("./ffmpeg2pass-0.log.mbtree")
-		if err != nil {
-			log.Println("Error removing 2-pass log files: %v\n", err)
+		// Remove 2 pass log files (.mbtree for x264, .cutree for x265)
+		logFiles, _ := filepath.Glob("./ffmpeg2pass-0.log*")
+		for _, logFile := range logFiles {
+			err := os.Remove(logFile)
+			if err != nil {
+				log.Printf("Error removing 2-pass log files: %v\n", err)
+			}
 		}
 	}
-
+	return outputName
 }
 
-func audioEncode(filePath string, bitrate float32, codecType int, duration float64) {
+// Encodes the audio and returns the output path, or "" if encoding failed
+func audioEncode(filePath string, opts AudioOptions) string {
 	var fileName string = filepath.Base(filePath)
-	var outputName = filepath.Dir(filePath) + `\` + strings.TrimSuffix(fileName, filepath.Ext(fileName))
-	var strMaxBitrate = strconv.FormatFloat(float64(bitrate), 'f', -1, 64)
-	var ffmpegArguments = ffmpeg.KwArgs{}
-	if codecType == 0 { //mp3
-		ffmpegArguments = ffmpeg.KwArgs{
-			"vn":  "",
-			"c:a": "libmp3lame",
-			"b:a": strMaxBitrate + "k",
-		}
-		outputName = outputName + "_mp3.mp3"
-	} else { //opus
-		ffmpegArguments = ffmpeg.KwArgs{
-			"vn":  "",
-			"c:a": "libopus",
-			"b:a": strMaxBitrate + "k",
-		}
-		outputName = outputName + "_opus.opus"
-	}
+	var outputName = filepath.Dir(filePath) + `\` + strings.TrimSuffix(fileName, filepath.Ext(fileName)) + audioFormats[opts.Codec].Suffix
+	var ffmpegArguments = audioCodecArgs(opts)
+	duration := opts.Duration
 	log.Printf("arguments: %v\n", ffmpegArguments)
 
 	audioErr := ffmpeg.Input(filePath).Output(outputName, ffmpegArguments).GlobalArgs("-progress", TempTCPProgress(duration)).OverWriteOutput().SetFfmpegPath("./ffmpeg.exe").ErrorToStdOut().Run()
 
 	if audioErr != nil {
 		encodeError = true
-		log.Println("Error occurred while encoding mp3: ", audioErr)
-		return
+		log.Println("Error occurred while encoding audio: ", audioErr)
+		return ""
 	} else {
 		log.Println("Encoded audio file!")
 	}
 	encodingNow = false
-}
-
-// TODO: Needs more research for gif compression
-func gifConvert(filePath string) {
-	var fileName string = filepath.Base(filePath)
-	var outputName = filepath.Dir(filePath) + `\` + strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "_gif.gif"
-
-	gifErr := ffmpeg.Input(filePath).Output(outputName, ffmpeg.KwArgs{
-		"filter_complex": "fps=15,split[v1][v2]; [v1]palettegen=stats_mode=full [palette]; [v2][palette]paletteuse=dither=sierra2_4a",
-		"vsync":          "0",
-		"y":              "",
-		"loop":           "0",
-	}).OverWriteOutput().SetFfmpegPath("./ffmpeg.exe").ErrorToStdOut().Run()
-
-	if gifErr != nil {
-		encodeError = true
-		log.Println("Error occurred while encoding gif: %v", gifErr)
-		return
-	} else {
-		log.Println("Encoded file to .gif")
-	}
-	encodingNow = false
-
+	return outputName
 }
 
 // Calculates the target bitrate in kilobits per second
-func calculateTarget(targetSize float32, duration float32, conservative bool) float32 {
-	var realTarget = targetSize * 8000 // kilobit conversion
+func calculateTarget(targetBytes int64, duration float32, conservative bool) float32 {
+	var realTarget = float32(targetBytes) * 8 / 1000 // kilobit conversion
 	var targetBitrate = realTarget / duration
 	if conservative {
 		return (targetBitrate * 0.98)
@@ -247,6 +404,21 @@ func calculateTarget(targetSize float32, duration float32, conservative bool) fl
 	}
 }
 
+// Lowest video bitrate worth encoding, below this the audio is taking up the target size
+const minVideoBitrate = 16
+
+// Takes the audio bitrate out of the total bitrate and returns what is left for the video
+func videoBitrateBudget(totalBitrate float32, audioBitrate int, hasAudio bool) (float32, error) {
+	if !hasAudio {
+		return totalBitrate, nil
+	}
+	videoBitrate := totalBitrate - float32(audioBitrate)
+	if videoBitrate < minVideoBitrate {
+		return 0, fmt.Errorf("%d Kb/s audio leaves no room for the video in the target size, lower the audio bitrate or choose No Audio", audioBitrate)
+	}
+	return videoBitrate, nil
+}
+
 func TempTCPProgress(totalDuration float64) string {
 	ln, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -293,3 +465,33 @@ func TempTCPProgress(totalDuration float64) string {
 
 	return "http://" + addr
 }
+
+// Copies extra arguments into the ffmpeg arguments, replacing any already set
+func addArgs(ffmpegArguments ffmpeg.KwArgs, extra ffmpeg.KwArgs) {
+	for key, value := range extra {
+		ffmpegArguments[key] = value
+	}
+}
+
+// Runs ffmpeg for analysis passes and returns its log output for parsing
+func runFFmpegLog(args ...string) (string, error) {
+	cmd := exec.Command("./ffmpeg.exe", args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stderr.String(), err
+}
+
+// Runs ffmpeg and returns what it writes to stdout, used for decoding raw samples
+func runFFmpegStdout(args ...string) ([]byte, error) {
+	cmd := exec.Command("./ffmpeg.exe", args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		log.Printf("ffmpeg error: %s", stderr.String())
+	}
+	return out, err
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"image/color"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
@@ -19,9 +23,83 @@ var filePath string
 var videoCompression int = 0
 var audioCompression int = 0
 var strTargetSize string = "10"
+var targetSizeUnit int32
+var strVideoAudioBitrate string = "96"
+var videoAudioCodec int32 = videoAudioOpus
+var videoAudioMono bool
+var autoAudioBitrate bool
+var addedAudioMode int32
+var addedAudioPath string
+var addedAudioLoop bool = true
+var addedAudioVolumes = []int32{100, 100, 30} // per mode, a replaced soundtrack plays at full volume and music goes under the video's audio
+var audioContent int32 = contentMixed
+var maxResolution int32
+var selectedPlatform int32
+var selectedPreset int32
+var strPresetName string
 var strAudioBitrate string = "160"
+var audioRateMode int = audioRateBitrate
+var strAudioQuality string = "2"
+var flacCompression int32 = 5
+var strAudioTargetSize string = "10"
+var audioTargetUnit int32
+var voiceMessage bool
+var audioToVideo bool
+var audioVisual int32
+var audioVisualImage string
+var metadataPolicy int32 = metadataStripPrivate
+var metadataViewOpen bool
+var selectedAsset int32
+var assetFormat int32 // index into the selected asset's animated formats
+var assetAnimated bool = true
+var strAssetStart string = "0"
+var strAssetLength string = "3"
+var animatedFormat int32
+var strAnimatedFPS string = "15"
+var animatedWidth int32 = 2
+var strAnimatedPlays string = "0"
+var strAnimatedStart string = "0"
+var strAnimatedLength string
+var animatedTarget bool = true
+var strAnimatedTargetSize string = "10"
+var animatedTargetUnit int32
+var imageFormat int32 = imageWebP
+var imageMaxSize int32
+var strImageTargetSize string = "2"
+var imageTargetUnit int32
+var normalizeLoudness bool
+var loudnessTarget int32
+var cropMode int32
+var strCrop string
+var cropDetecting bool
+var cropStatus string
+var videoRotation int32
+var flipHorizontal bool
+var flipVertical bool
+var maskRegions []MaskRegion
+var concatMode bool
+var concatClips []ConcatClip
+var concatCrossfade bool
+var strCrossfade string = "0.5"
+var concatStatus string
+var playbackSpeed int32 = normalSpeed
+var playbackEffect int32
+var selectedSubtitle int32
+var subtitleFile string
+var strCaptionTop string
+var strCaptionBottom string
+var captionStyle int32
+var captionFont int32
+var captionSize int32 = 10
+var captionOutline int32 = 3
+var strCaptionStart string
+var strCaptionEnd string
 var fsArgument bool
 var conservativeBitrate bool = true
+var requireIOSPlayback bool
+var qualityScoring bool
+var qualityMetric int32 = metricVMAF
+var saveResultJSON bool
 
 // Popup Modal Variables
 var encodingNow bool
@@ -30,6 +108,11 @@ var encodingDone bool
 
 var encodingFirstPass bool
 var encodingSecondPass bool
+var scoringNow bool
+var loudnessNow bool
+var encodingCodec int
+var autoCodecStatus string
+var attemptStatus string
 
 // Error variables
 var invalidFile bool
@@ -38,6 +121,7 @@ var ffmpegNotFound bool
 var ffprobeNotFound bool
 var invalidFFmpeg bool
 var invalidFFprobe bool
+var settingsError string
 
 // Progress variable
 var progressStr string
@@ -47,10 +131,21 @@ func beginEncode() {
 	encodingFirstPass = true
 	encodingNow = true
 	// Parse the target bitrate value from the GUI
-	targetFileSize, err := strconv.ParseFloat(strTargetSize, 32)
+	targetBytes, err := parseTargetSize(strTargetSize, int(targetSizeUnit))
 	if err != nil {
 		log.Println("Error with parsing file size: ", err)
-		log.Println(targetFileSize)
+		settingsError = err.Error()
+		encodingNow = false
+		encodingFirstPass = false
+		return
+	}
+	videoAudioBitrate, err := strconv.Atoi(strVideoAudioBitrate)
+	if videoAudioCodec != videoAudioNone && !autoAudioBitrate && (err != nil || videoAudioBitrate <= 0) {
+		log.Println("Error with parsing audio bitrate: ", err)
+		settingsError = "Audio bitrate must be a whole number above 0"
+		encodingNow = false
+		encodingFirstPass = false
+		return
 	}
 
 	// Retrieve and parse video file information
@@ -69,15 +164,408 @@ func beginEncode() {
 		return
 	}
 
+	// Hidden regions are placed on the source frame, before it is cropped or scaled
+	width, height := mediaInfo.displaySize()
+	regions := append([]MaskRegion{}, maskRegions...)
+	if err := validateMasks(regions); err != nil {
+		settingsError = err.Error()
+		encodingNow = false
+		encodingFirstPass = false
+		return
+	}
+	masks := maskFilters(regions, width, height)
+
+	// Crop and rotate first, the size that's left is what gets fitted and scaled
+	transform, cropNote, err := transformSettings(filePath, duration, width, height)
+	if err != nil {
+		settingsError = err.Error()
+		encodingNow = false
+		encodingFirstPass = false
+		return
+	}
+	width, height = transform.size(width, height)
+
+	// Fit the settings within the chosen platform's limits.
+	// Animated GIFs and WebPs always become H264 MP4s so they embed like a looping video
+	platform := platformProfiles[selectedPlatform]
+	codec := videoCompression
+	animatedImage := mediaInfo.animatedImage()
+	if animatedImage {
+		codec = codecH264
+	}
+
+	// Speed and effects change how long the output is, which the plan and bitrate are worked out from.
+	// GIFs keep their own frame delays so their frame rate is left alone
+	playback := PlaybackOptions{Speed: playbackSpeeds[playbackSpeed], Effect: int(playbackEffect)}
+	if !animatedImage {
+		playback.FrameRate = mediaInfo.frameRate()
+	}
+	if err := playback.check(duration); err != nil {
+		settingsError = err.Error()
+		encodingNow = false
+		encodingFirstPass = false
+		return
+	}
+	plan := planEncode(platform, targetBytes, codec, width, height, resolutionSizes[maxResolution], playback.outputDuration(duration))
+	caption, err := captionSettings()
+	if err != nil {
+		settingsError = err.Error()
+		encodingNow = false
+		encodingFirstPass = false
+		return
+	}
+	plan.fitCaption(platform, caption)
+	for _, warning := range plan.Warnings {
+		log.Println("warning:", warning)
+	}
+	lastResult = EncodeResult{Source: filePath, Platform: platform.Name, TargetBytes: plan.TargetBytes, Warnings: plan.Warnings}
+	if cropNote != "" {
+		lastResult.Notes = append(lastResult.Notes, cropNote)
+	}
+
+	// The trim only cuts the end of the video so the subtitles need no offset
+	refreshAudioTracks()
+	subtitles, err := subtitleSettings(filePath, 0)
+	if err != nil {
+		settingsError = err.Error()
+		encodingNow = false
+		encodingFirstPass = false
+		return
+	}
+
+	// Work out if the output has audio at all, the video gets the whole budget if it doesn't
+	tracks := append([]AudioTrack{}, audioTracks...)
+	if addedAudioMode != addedAudioOff {
+		if _, err := os.Stat(addedAudioPath); err != nil {
+			settingsError = "Select the audio file to add to the video"
+			encodingNow = false
+			encodingFirstPass = false
+			return
+		}
+		tracks = addAudioFile(tracks, int(addedAudioMode), addedAudioPath, addedAudioLoop, addedAudioVolumes[addedAudioMode])
+	}
+	if playback.Effect == effectReverse && hasAddedAudio(tracks) {
+		settingsError = "Reverse can't be used with an added audio file"
+		encodingNow = false
+		encodingFirstPass = false
+		return
+	}
+	audioCodec := int(videoAudioCodec)
+	if (!mediaInfo.hasAudio() && !hasAddedAudio(tracks)) || (len(tracks) > 0 && len(enabledTracks(tracks)) == 0) {
+		audioCodec = videoAudioNone
+	}
+	if playback.Effect == effectBoomerang && audioCodec != videoAudioNone {
+		audioCodec = videoAudioNone
+		lastResult.Notes = append(lastResult.Notes, "Boomerangs have no audio")
+	}
+
 	// Calculate target bitrate and then compress
-	var target = calculateTarget(float32(targetFileSize), float32(duration), conservativeBitrate)
-	videoEncode(filePath, float32(target), videoCompression, duration)
+	var total = calculateTarget(plan.TargetBytes, float32(plan.Duration), conservativeBitrate)
+	audioMono := videoAudioMono
+	if autoAudioBitrate && audioCodec != videoAudioNone {
+		allocation := allocateAudio(total, int(audioContent), audioCodec)
+		videoAudioBitrate, audioMono = allocation.Bitrate, allocation.Mono
+		lastResult.AudioChoice = allocation.String()
+		log.Println(lastResult.AudioChoice)
+	}
+	target, err := videoBitrateBudget(total, videoAudioBitrate, audioCodec != videoAudioNone)
+	if err != nil {
+		log.Println("Error with bitrate budget: ", err)
+		settingsError = err.Error()
+		encodingNow = false
+		encodingFirstPass = false
+		return
+	}
+	opts := VideoOptions{
+		Codec:        plan.Codec,
+		Bitrate:      target,
+		AudioCodec:   audioCodec,
+		AudioBitrate: videoAudioBitrate,
+		AudioMono:    audioMono,
+		AudioTracks:  tracks,
+		Metadata:     int(metadataPolicy),
+		Tags:         mediaInfo.Format.Tags,
+		Duration:     plan.Duration,
+		Trim:         plan.Trimmed,
+		TargetBytes:  plan.TargetBytes,
+	}
+	opts.Filters = append(opts.Filters, transform.filters()...)
+	if scale := plan.scaleFilter(width, height); scale != "" {
+		opts.Filters = append(opts.Filters, scale)
+	}
+	if animatedImage {
+		// yuv420p needs even dimensions, which GIFs often don't have
+		opts.Filters = append(opts.Filters, "scale=trunc(iw/2)*2:trunc(ih/2)*2", "format=yuv420p")
+		opts.KeepTiming = true
+		if videoCompression != codecH264 {
+			lastResult.Warnings = append(lastResult.Warnings, "Animated images are converted to H264 MP4 so they embed like a GIF")
+		}
+	}
+	opts.AudioFilters = append(opts.AudioFilters, playback.audioFilters()...)
+	if normalizeLoudness && audioCodec != videoAudioNone {
+		// The loudness is measured on the source, which runs at a different speed than the output
+		var measureDuration float64
+		if plan.Trimmed || hasAddedAudio(tracks) {
+			measureDuration = plan.Duration * playback.Speed
+		}
+		filter, loudness, warning := planLoudness(filePath, loudnessTargets[loudnessTarget], measureDuration, opts.AudioTracks)
+		if filter != "" {
+			opts.AudioFilters = append(opts.AudioFilters, filter)
+		}
+		if warning != "" {
+			lastResult.Warnings = append(lastResult.Warnings, warning)
+		}
+		lastResult.Loudness = loudness
+	}
+
+	// Trial encode samples to find the best codec before the real encode
+	if plan.Codec == codecAuto {
+		opts.Codec, lastResult.AutoCodec = pickAutoCodec(filePath, opts, playback, platform, width, height, transform.filters())
+	}
+	if opts.Codec == codecVP9 && opts.AudioCodec == videoAudioAAC {
+		opts.AudioCodec = videoAudioOpus
+		lastResult.Warnings = append(lastResult.Warnings, "WebM can't hold AAC audio, using Opus instead")
+	}
+
+	// Hidden regions, subtitles and the caption go on after the codec is picked so the samples are
+	// scored against the original frames, the samples get the playback effects on their own.
+	// Subtitles are drawn before the speed changes so they keep the source timing, the caption times are in output time
+	opts.Filters = append(append([]string{}, masks...), opts.Filters...)
+	opts.Subtitles = subtitles
+	opts.Filters = append(opts.Filters, subtitles.filters()...)
+	opts.Filters = append(opts.Filters, playback.videoFilters(0)...)
+	opts.Filters = append(opts.Filters, caption.filters(plan.Width, plan.Height)...)
+	encodingCodec = opts.Codec
+	lastResult.Output = videoEncode(filePath, opts)
+
+	// Check the output against the same byte limit the bitrate was calculated from
+	if lastResult.Output != "" {
+		var warning string
+		lastResult.OutputBytes, warning = verifyOutputSize(lastResult.Output, plan.TargetBytes)
+		if warning != "" {
+			lastResult.Warnings = append(lastResult.Warnings, warning)
+		}
+	}
+
+	// Optionally score the compressed output against the original
+	if qualityScoring && lastResult.Output != "" && plan.PadHeight > 0 {
+		lastResult.Warnings = append(lastResult.Warnings, "Quality Score was skipped since the caption bar changes the frame")
+	} else if qualityScoring && lastResult.Output != "" && playback.changed() {
+		lastResult.Warnings = append(lastResult.Warnings, "Quality Score was skipped since the speed or effect changes the timing")
+	} else if qualityScoring && lastResult.Output != "" {
+		scoringNow = true
+		lastResult.Quality = scoreQuality(lastResult.Output, filePath, int(qualityMetric), width, height, plan.Duration, append(append([]string{}, masks...), transform.filters()...))
+		scoringNow = false
+	}
+	saveResult(lastResult)
+
+	encodingNow = false
+	encodingDone = true
+	beep.Alert("Discord Media Tool", "Video Encoding Complete!", "")
+}
+
+// Joins the clip list into one video, the bitrate is worked out from the length of the joined video
+func beginConcatEncode() {
+	encodingFirstPass = true
+	encodingNow = true
+	targetBytes, err := parseTargetSize(strTargetSize, int(targetSizeUnit))
+	if err != nil {
+		log.Println("Error with parsing file size: ", err)
+		settingsError = err.Error()
+		encodingNow = false
+		encodingFirstPass = false
+		return
+	}
+	videoAudioBitrate, err := strconv.Atoi(strVideoAudioBitrate)
+	if videoAudioCodec != videoAudioNone && !autoAudioBitrate && (err != nil || videoAudioBitrate <= 0) {
+		log.Println("Error with parsing audio bitrate: ", err)
+		settingsError = "Audio bitrate must be a whole number above 0"
+		encodingNow = false
+		encodingFirstPass = false
+		return
+	}
+
+	concat := ConcatOptions{Clips: append([]ConcatClip{}, concatClips...)}
+	if concatCrossfade {
+		concat.Crossfade, err = strconv.ParseFloat(strCrossfade, 64)
+		if err != nil || concat.Crossfade <= 0 {
+			settingsError = "Crossfade must be a number of seconds"
+			encodingNow = false
+			encodingFirstPass = false
+			return
+		}
+	}
+	if err := concat.check(); err != nil {
+		settingsError = err.Error()
+		encodingNow = false
+		encodingFirstPass = false
+		return
+	}
+	regions := append([]MaskRegion{}, maskRegions...)
+	if err := validateMasks(regions); err != nil {
+		settingsError = err.Error()
+		encodingNow = false
+		encodingFirstPass = false
+		return
+	}
+
+	// Every clip is fitted into the size of the first one, then the platform limits apply as usual
+	first := concat.Clips[0]
+	platform := platformProfiles[selectedPlatform]
+	plan := planEncode(platform, targetBytes, videoCompression, first.Width, first.Height, resolutionSizes[maxResolution], concat.duration())
+	caption, err := captionSettings()
+	if err != nil {
+		settingsError = err.Error()
+		encodingNow = false
+		encodingFirstPass = false
+		return
+	}
+	plan.fitCaption(platform, caption)
+	concat.Width, concat.Height, concat.FPS = plan.Width, plan.Height, concatFrameRate(concat.Clips)
+	for _, warning := range plan.Warnings {
+		log.Println("warning:", warning)
+	}
+	lastResult = EncodeResult{Source: first.Path, Platform: platform.Name, TargetBytes: plan.TargetBytes, Warnings: plan.Warnings}
+	lastResult.Notes = append(lastResult.Notes, fmt.Sprintf("Combined %d clips into %.1f seconds at %dx%d, %.0f fps", len(concat.Clips), concat.duration(), concat.Width, concat.Height, concat.FPS))
+
+	// Clips without audio get silence, so there's only no audio if none of them has any
+	audioCodec := videoAudioNone
+	for _, clip := range concat.Clips {
+		if clip.HasAudio {
+			audioCodec = int(videoAudioCodec)
+		}
+	}
+
+	var total = calculateTarget(plan.TargetBytes, float32(plan.Duration), conservativeBitrate)
+	audioMono := videoAudioMono
+	if autoAudioBitrate && audioCodec != videoAudioNone {
+		allocation := allocateAudio(total, int(audioContent), audioCodec)
+		videoAudioBitrate, audioMono = allocation.Bitrate, allocation.Mono
+		lastResult.AudioChoice = allocation.String()
+		log.Println(lastResult.AudioChoice)
+	}
+	target, err := videoBitrateBudget(total, videoAudioBitrate, audioCodec != videoAudioNone)
+	if err != nil {
+		log.Println("Error with bitrate budget: ", err)
+		settingsError = err.Error()
+		encodingNow = false
+		encodingFirstPass = false
+		return
+	}
+	opts := VideoOptions{
+		Codec:        plan.Codec,
+		Bitrate:      target,
+		AudioCodec:   audioCodec,
+		AudioBitrate: videoAudioBitrate,
+		AudioMono:    audioMono,
+		Metadata:     int(metadataPolicy),
+		Duration:     plan.Duration,
+		Trim:         plan.Trimmed,
+		TargetBytes:  plan.TargetBytes,
+	}
+
+	// Auto codec samples come from the first clip on its own
+	if plan.Codec == codecAuto {
+		sample := opts
+		sample.Duration = first.Duration
+		opts.Codec, lastResult.AutoCodec = pickAutoCodec(first.Path, sample, PlaybackOptions{Speed: 1}, platform, first.Width, first.Height, nil)
+	}
+	if opts.Codec == codecVP9 && opts.AudioCodec == videoAudioAAC {
+		opts.AudioCodec = videoAudioOpus
+		lastResult.Warnings = append(lastResult.Warnings, "WebM can't hold AAC audio, using Opus instead")
+	}
+	opts.Concat = &concat
+	opts.Filters = append(maskFilters(regions, concat.Width, concat.Height), caption.filters(plan.Width, plan.Height)...)
+	encodingCodec = opts.Codec
+	lastResult.Output = videoEncode(first.Path, opts)
+
+	if lastResult.Output != "" {
+		var warning string
+		lastResult.OutputBytes, warning = verifyOutputSize(lastResult.Output, plan.TargetBytes)
+		if warning != "" {
+			lastResult.Warnings = append(lastResult.Warnings, warning)
+		}
+	}
+	if qualityScoring && lastResult.Output != "" {
+		lastResult.Warnings = append(lastResult.Warnings, "Quality Score was skipped since combined clips have no single original to compare against")
+	}
+	saveResult(lastResult)
 
 	encodingNow = false
 	encodingDone = true
 	beep.Alert("Discord Media Tool", "Video Encoding Complete!", "")
 }
 
+// Probes a clip and adds it to the end of the clip list
+func addConcatClip(path string) {
+	concatStatus = "Reading " + filepath.Base(path) + "..."
+	clip, err := loadConcatClip(path)
+	if err != nil {
+		concatStatus = err.Error()
+		return
+	}
+	concatClips = append(concatClips, clip)
+	concatStatus = ""
+}
+
+// Swaps the clip with the one before or after it
+func moveConcatClip(i int, step int) {
+	j := i + step
+	if j < 0 || j >= len(concatClips) {
+		return
+	}
+	concatClips[i], concatClips[j] = concatClips[j], concatClips[i]
+}
+
+func concatLayout() g.Widget {
+	rows := g.Layout{}
+	for i, clip := range concatClips {
+		rows = append(rows, g.Row(
+			g.Button(fmt.Sprintf("Up##ConcatUp%d", i)).OnClick(func() { moveConcatClip(i, -1) }),
+			g.Button(fmt.Sprintf("Down##ConcatDown%d", i)).OnClick(func() { moveConcatClip(i, 1) }),
+			g.Button(fmt.Sprintf("Remove##ConcatRemove%d", i)).OnClick(func() {
+				concatClips = append(concatClips[:i:i], concatClips[i+1:]...)
+			}),
+			g.Label(fmt.Sprintf("%d. %s", i+1, clip.label())),
+		))
+	}
+	return g.Layout{
+		g.Row(
+			g.Checkbox("Combine Clips", &concatMode),
+			g.Tooltip("Combine tip").Layout(
+				g.BulletText("Joins several clips into one video in the order listed, like a highlight reel"),
+				g.BulletText("Every clip is fitted to the size of the first one, with black bars if the shape differs"),
+				g.BulletText("The bitrate is worked out from the length of the joined video"),
+				g.BulletText("Crop, subtitles, speed, audio tracks and loudness only apply to single videos"),
+				g.BulletText("Hidden regions are timed from the start of the combined video"),
+			),
+		),
+		g.Condition(concatMode, g.Layout{
+			rows,
+			g.Row(
+				g.Button("Add Clip...").OnClick(func() {
+					filename, err := dialog.File().Title("Select a Clip").Load()
+					if err != nil {
+						log.Println(err)
+						return
+					}
+					go addConcatClip(strings.ReplaceAll(filename, `\`, "/"))
+				}),
+				g.Checkbox("Crossfade", &concatCrossfade),
+				g.Condition(concatCrossfade, g.Layout{
+					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+							g.InputText(&strCrossfade).Size(40),
+						),
+					),
+					g.Label("seconds"),
+				}, g.Layout{}),
+				g.Label(concatStatus),
+			),
+		}, g.Layout{}),
+	}
+}
+
 func beginAudioConvert() {
 	// Probe the file for audio details
 	// .mp3, .m4a, .m4a(aac non-apple), .opus, .flac, .wav
@@ -99,43 +587,757 @@ func beginAudioConvert() {
 		return
 	}
 
-	duration, err := strconv.ParseFloat(mediaInfo.Format.Duration, 32)
-	if err != nil {
-		log.Println("Error parsing audio duration: ", err)
-		encodingNow = false
-		audioEncodingNow = false
-		return
+	duration, err := strconv.ParseFloat(mediaInfo.Format.Duration, 32)
+	if err != nil {
+		log.Println("Error parsing audio duration: ", err)
+		encodingNow = false
+		audioEncodingNow = false
+		return
+	}
+	lastResult = EncodeResult{Source: filePath}
+
+	// Pick which tracks of multi-track recordings end up in the output
+	refreshAudioTracks()
+	tracks := append([]AudioTrack{}, audioTracks...)
+	if err := checkAudioTracks(tracks); err != nil {
+		settingsError = err.Error()
+		encodingNow = false
+		audioEncodingNow = false
+		return
+	}
+
+	var audioFilters []string
+	if normalizeLoudness {
+		filter, loudness, warning := planLoudness(filePath, loudnessTargets[loudnessTarget], 0, tracks)
+		if filter != "" {
+			audioFilters = append(audioFilters, filter)
+		}
+		if warning != "" {
+			lastResult.Warnings = append(lastResult.Warnings, warning)
+		}
+		lastResult.Loudness = loudness
+	}
+
+	// Audio to video makes an MP4 under the target size so skip the audio format options
+	if audioToVideo {
+		targetBytes, err := parseTargetSize(strAudioTargetSize, int(audioTargetUnit))
+		if err != nil {
+			log.Println("Error with parsing file size: ", err)
+			settingsError = err.Error()
+			encodingNow = false
+			audioEncodingNow = false
+			return
+		}
+		opts := AudioVideoOptions{
+			Visual:    int(audioVisual),
+			ImagePath: audioVisualImage,
+			CoverArt:  mediaInfo.coverArt(),
+			Duration:  duration,
+			Tracks:    tracks,
+			Filters:   audioFilters,
+			Metadata:  int(metadataPolicy),
+			Tags:      mediaInfo.Format.Tags,
+		}
+		if opts.Visual == visualImage {
+			if _, err := os.Stat(opts.ImagePath); err != nil {
+				settingsError = "Select the image to show in the video"
+				encodingNow = false
+				audioEncodingNow = false
+				return
+			}
+		}
+		if opts.Visual == visualCoverArt && opts.CoverArt < 0 {
+			opts.Visual = visualWaveform
+			lastResult.Warnings = append(lastResult.Warnings, "No cover art found in the file, using a waveform instead")
+		}
+
+		total := calculateTarget(targetBytes, float32(duration), true)
+		var warning string
+		opts.VideoBitrate, opts.AudioBitrate, warning = audioVideoBudget(total, opts.Visual)
+		if warning != "" {
+			lastResult.Warnings = append(lastResult.Warnings, warning)
+		}
+		lastResult.TargetBytes = targetBytes
+		lastResult.AudioChoice = fmt.Sprintf("Audio: %d Kb/s AAC, video: %d Kb/s %s", opts.AudioBitrate, opts.VideoBitrate, strings.ToLower(audioVisualNames[opts.Visual]))
+		log.Println(lastResult.AudioChoice)
+		lastResult.Output = audioToVideoEncode(filePath, opts)
+		finishAudioConvert()
+		return
+	}
+
+	// Voice messages have fixed settings so skip the format options
+	if voiceMessage {
+		if !ffmpegHasEncoder("libopus") {
+			settingsError = "Your FFmpeg build can't encode Opus"
+			encodingNow = false
+			audioEncodingNow = false
+			return
+		}
+		lastResult.Output = voiceEncode(filePath, AudioOptions{
+			Codec:    audioOpus,
+			Duration: duration,
+			Tracks:   tracks,
+			Filters:  audioFilters,
+			Metadata: int(metadataPolicy),
+			Tags:     mediaInfo.Format.Tags,
+		})
+		if lastResult.Output != "" {
+			voice, err := writeVoiceSidecar(lastResult.Output)
+			if err != nil {
+				log.Println("Error creating voice message waveform: ", err)
+				lastResult.Warnings = append(lastResult.Warnings, "Couldn't create the voice message waveform")
+			}
+			lastResult.Voice = voice
+		}
+		finishAudioConvert()
+		return
+	}
+
+	// Check the local ffmpeg can encode the chosen format
+	format := audioFormats[audioCompression]
+	opts := AudioOptions{
+		Codec:            audioCompression,
+		Encoder:          format.encoder(),
+		RateMode:         audioRateMode,
+		CompressionLevel: int(flacCompression),
+		Filters:          audioFilters,
+		Tracks:           tracks,
+		Metadata:         int(metadataPolicy),
+		Tags:             mediaInfo.Format.Tags,
+		CoverArt:         mediaInfo.coverArt(),
+		Duration:         duration,
+	}
+	if opts.Encoder == "" {
+		settingsError = "Your FFmpeg build can't encode " + format.Name
+		encodingNow = false
+		audioEncodingNow = false
+		return
+	}
+	if format.Lossless {
+		opts.RateMode = audioRateBitrate
+	}
+
+	// Parse bitrate string, or work it out from the target size
+	switch {
+	case format.Lossless:
+		// Lossless formats have no bitrate to pick
+	case opts.RateMode == audioRateTarget:
+		targetBytes, err := parseTargetSize(strAudioTargetSize, int(audioTargetUnit))
+		if err != nil {
+			log.Println("Error with parsing file size: ", err)
+			settingsError = err.Error()
+			encodingNow = false
+			audioEncodingNow = false
+			return
+		}
+		bitrate, warnings := calculateAudioTarget(targetBytes, duration, audioCompression)
+		opts.Bitrate = bitrate
+		lastResult.TargetBytes = targetBytes
+		lastResult.Warnings = warnings
+		log.Printf("target size bitrate: %v Kb/s", opts.Bitrate)
+	case opts.RateMode == audioRateQuality:
+		quality, err := strconv.ParseFloat(strAudioQuality, 32)
+		if err == nil {
+			err = format.validateQuality(float32(quality))
+		}
+		if err == nil && !format.hasQuality(opts.Encoder) {
+			if audioCompression == audioAAC {
+				err = errors.New("AAC quality mode needs an FFmpeg build with libfdk_aac")
+			} else {
+				err = errors.New(format.Name + " only takes a bitrate")
+			}
+		}
+		if err != nil {
+			log.Println("Error parsing audio quality: ", err)
+			settingsError = err.Error()
+			encodingNow = false
+			audioEncodingNow = false
+			return
+		}
+		opts.Quality = float32(quality)
+	default:
+		audioBitrate, err := strconv.ParseFloat(strAudioBitrate, 32)
+		if err != nil {
+			log.Println("Error parsing audio information: ", err)
+			encodingNow = false
+			audioEncodingNow = false
+			return
+		}
+		opts.Bitrate = float32(audioBitrate)
+	}
+
+	// Encode the audio into a audio
+	lastResult.Output = audioEncode(filePath, opts)
+	finishAudioConvert()
+}
+
+// Checks the output size, saves the result and shows the completion popup
+func finishAudioConvert() {
+	if lastResult.Output != "" && lastResult.TargetBytes > 0 {
+		var warning string
+		lastResult.OutputBytes, warning = verifyOutputSize(lastResult.Output, lastResult.TargetBytes)
+		if warning != "" {
+			lastResult.Warnings = append(lastResult.Warnings, warning)
+		}
+	}
+	saveResult(lastResult)
+
+	audioEncodingNow = false
+	encodingNow = false
+	encodingDone = true
+	beep.Alert("Discord Media Tool", "Audio Encoding Complete!", "")
+}
+
+func beginAssetConvert() {
+	encodingNow = true
+	// Images have no duration so only check that there is a picture
+	mediaInfo := getMediaInfo(filePath, "video")
+	if width, _ := mediaInfo.videoSize(); invalidFile || width == 0 {
+		log.Println("Aborting asset due to file error")
+		encodingNow = false
+		return
+	}
+
+	opts := AssetOptions{Spec: assetSpecs[selectedAsset], Animated: assetAnimated}
+	opts.Format = opts.Spec.Animated[assetFormat]
+	start, err := strconv.ParseFloat(strAssetStart, 64)
+	if err != nil || start < 0 {
+		settingsError = "Start must be a number of seconds"
+		encodingNow = false
+		return
+	}
+	length, err := strconv.ParseFloat(strAssetLength, 64)
+	if opts.Animated && (err != nil || length <= 0) {
+		settingsError = "Length must be a number of seconds above 0"
+		encodingNow = false
+		return
+	}
+	opts.Start, opts.Length = start, length
+
+	// Still images only have one frame so there is nothing to animate
+	duration, _ := strconv.ParseFloat(mediaInfo.Format.Duration, 64)
+	lastResult = EncodeResult{Source: filePath, TargetBytes: opts.Spec.MaxBytes}
+	if opts.Animated && duration <= 0.1 {
+		opts.Animated = false
+		lastResult.Warnings = append(lastResult.Warnings, "The source is a still image, made a still "+opts.Spec.Name)
+	}
+	if opts.Animated && !ffmpegHasEncoder(animatedEncoders[opts.Format]) {
+		settingsError = "Your FFmpeg build can't encode " + animatedFormatNames[opts.Format]
+		encodingNow = false
+		return
+	}
+
+	var note string
+	lastResult.Output, note = assetEncode(filePath, opts)
+	attemptStatus = ""
+	if note != "" {
+		lastResult.Notes = append(lastResult.Notes, note)
+	}
+	if lastResult.Output != "" {
+		var warning string
+		lastResult.OutputBytes, warning = verifyOutputSize(lastResult.Output, opts.Spec.MaxBytes)
+		if warning != "" {
+			lastResult.Warnings = append(lastResult.Warnings, warning)
+		}
+	}
+	saveResult(lastResult)
+
+	encodingNow = false
+	encodingDone = true
+	beep.Alert("Discord Media Tool", opts.Spec.Name+" Complete!", "")
+}
+
+func beginImageConvert() {
+	encodingNow = true
+	mediaInfo := getMediaInfo(filePath, "video")
+	width, height := mediaInfo.videoSize()
+	if invalidFile || width == 0 {
+		log.Println("Aborting image due to file error")
+		encodingNow = false
+		return
+	}
+
+	opts := ImageOptions{Format: int(imageFormat), MaxSize: imageMaxSizes[imageMaxSize]}
+	if !ffmpegHasEncoder(imageEncoders[opts.Format]) {
+		settingsError = "Your FFmpeg build can't encode " + imageFormatNames[opts.Format]
+		encodingNow = false
+		return
+	}
+	targetBytes, err := parseTargetSize(strImageTargetSize, int(imageTargetUnit))
+	if err != nil {
+		settingsError = err.Error()
+		encodingNow = false
+		return
+	}
+	opts.TargetBytes = targetBytes
+	lastResult = EncodeResult{Source: filePath, TargetBytes: targetBytes}
+
+	var note string
+	lastResult.Output, note = imageEncode(filePath, opts, width, height)
+	attemptStatus = ""
+	if note != "" {
+		lastResult.Notes = append(lastResult.Notes, note)
+	}
+	if lastResult.Output != "" {
+		var warning string
+		lastResult.OutputBytes, warning = verifyOutputSize(lastResult.Output, targetBytes)
+		if warning != "" {
+			lastResult.Warnings = append(lastResult.Warnings, warning)
+		}
+	}
+	saveResult(lastResult)
+
+	encodingNow = false
+	encodingDone = true
+	beep.Alert("Discord Media Tool", "Image Compression Complete!", "")
+}
+
+func beginAnimatedConvert() {
+	encodingNow = true
+	mediaInfo := getMediaInfo(filePath, "video")
+	if invalidFile || mediaInfo.Format.Duration == "invalid" {
+		log.Println("Aborting encode due to file error")
+		encodingNow = false
+		return
+	}
+	duration, _ := strconv.ParseFloat(mediaInfo.Format.Duration, 64)
+	// ffmpeg turns phone videos by their rotation before the filters run, so size from the played frame
+	sourceWidth, sourceHeight := mediaInfo.displaySize()
+
+	opts := AnimatedOptions{Format: int(animatedFormat), Width: animatedWidths[animatedWidth], SourceWidth: sourceWidth, SourceHeight: sourceHeight}
+	if !ffmpegHasEncoder(animatedEncoders[opts.Format]) {
+		settingsError = "Your FFmpeg build can't encode " + animatedFormatNames[opts.Format]
+		encodingNow = false
+		return
+	}
+	fps, err := strconv.Atoi(strAnimatedFPS)
+	if err != nil || fps <= 0 || fps > 50 {
+		settingsError = "FPS must be a whole number from 1 to 50"
+		encodingNow = false
+		return
+	}
+	plays, err := strconv.Atoi(strAnimatedPlays)
+	if err != nil || plays < 0 {
+		settingsError = "Plays must be 0 to loop forever or the number of times to play"
+		encodingNow = false
+		return
+	}
+	start, err := strconv.ParseFloat(strAnimatedStart, 64)
+	if err != nil || start < 0 {
+		settingsError = "Start must be a number of seconds"
+		encodingNow = false
+		return
+	}
+	var length float64
+	if strings.TrimSpace(strAnimatedLength) != "" {
+		length, err = strconv.ParseFloat(strAnimatedLength, 64)
+		if err != nil || length <= 0 {
+			settingsError = "Length must be a number of seconds, or empty for the rest of the video"
+			encodingNow = false
+			return
+		}
+	}
+	opts.Caption, err = captionSettings()
+	if err != nil {
+		settingsError = err.Error()
+		encodingNow = false
+		return
+	}
+	refreshAudioTracks()
+	opts.Subtitles, err = subtitleSettings(filePath, start)
+	if err != nil {
+		settingsError = err.Error()
+		encodingNow = false
+		return
+	}
+	opts.FPS, opts.Plays, opts.Start, opts.Length = fps, plays, start, length
+	opts.Duration = duration - start
+	if length > 0 {
+		opts.Duration = min(length, opts.Duration)
+	}
+	opts.Playback = PlaybackOptions{Speed: playbackSpeeds[playbackSpeed], Effect: int(playbackEffect)}
+	if err := opts.Playback.check(opts.Duration); err != nil {
+		settingsError = err.Error()
+		encodingNow = false
+		return
+	}
+	opts.Duration = opts.Playback.outputDuration(opts.Duration)
+
+	lastResult = EncodeResult{Source: filePath}
+	if animatedTarget {
+		opts.TargetBytes, err = parseTargetSize(strAnimatedTargetSize, int(animatedTargetUnit))
+		if err != nil {
+			settingsError = err.Error()
+			encodingNow = false
+			return
+		}
+		lastResult.TargetBytes = opts.TargetBytes
+	}
+
+	var note string
+	lastResult.Output, note = animatedEncode(filePath, opts)
+	attemptStatus = ""
+	if note != "" {
+		lastResult.Notes = append(lastResult.Notes, note)
+	}
+	if lastResult.Output != "" && opts.TargetBytes > 0 {
+		var warning string
+		lastResult.OutputBytes, warning = verifyOutputSize(lastResult.Output, opts.TargetBytes)
+		if warning != "" {
+			lastResult.Warnings = append(lastResult.Warnings, warning)
+		}
+	}
+	saveResult(lastResult)
+
+	encodingNow = false
+	encodingDone = true
+	beep.Alert("Discord Media Tool", "Animated Image Complete!", "")
+}
+
+// Checkbox and volume slider for every audio track, shared by the video and audio converters
+func audioTracksLayout() g.Widget {
+	if len(audioTracks) == 0 {
+		return g.Layout{}
+	}
+	layout := g.Layout{
+		g.Row(
+			g.Label("Audio Tracks"),
+			g.Tooltip("Tracks tip").Layout(
+				g.BulletText("Recordings from OBS or ShadowPlay can have separate game, mic and call tracks"),
+				g.BulletText("Checked tracks are mixed together, uncheck them all for no audio"),
+				g.BulletText("The slider sets the volume of each track"),
+			),
+		),
+	}
+	for i := range audioTracks {
+		layout = append(layout, g.Row(
+			g.Checkbox(audioTracks[i].label()+"##track"+strconv.Itoa(i), &audioTracks[i].Enabled),
+			g.SliderInt(&audioTracks[i].Volume, 0, 200).Label("##volume"+strconv.Itoa(i)).Format("%d%%").Size(150),
+		))
+	}
+	return layout
+}
+
+// Metadata policy and the button to view the file's metadata, shared by the video and audio converters
+func metadataRow() g.Widget {
+	return g.Row(
+		g.Label("Metadata"),
+		g.Combo("##Metadata", metadataPolicyNames[metadataPolicy], metadataPolicyNames, &metadataPolicy).Size(175),
+		g.Tooltip("Metadata tip").Layout(
+			g.BulletText("Keep All copies tags like title and artist, cover art and everything else"),
+			g.BulletText("Remove Location/Device keeps tags and cover art but removes GPS location and phone details"),
+			g.BulletText("Remove All strips every tag, chapter and cover art"),
+		),
+		g.Button("View Metadata").OnClick(func() {
+			metadataViewOpen = true
+			go loadMetadataView(filePath)
+		}),
+	)
+}
+
+// Loudness normalization settings, shared by the video and audio converters
+func loudnessRow() g.Widget {
+	return g.Row(
+		g.Checkbox("Normalize Loudness", &normalizeLoudness),
+		g.Tooltip("Loudness tip").Layout(
+			g.BulletText("Measures the audio first then evens out the volume to the chosen target"),
+			g.BulletText("-14 LUFS is loud like streaming sites, -23 LUFS is the quieter broadcast standard"),
+			g.BulletText("Takes an extra pass over the audio before encoding"),
+		),
+		g.Combo("##Loudness", loudnessTargetNames[loudnessTarget], loudnessTargetNames, &loudnessTarget).Size(150),
+	)
+}
+
+// Reads the crop, rotation and flip settings. In auto mode the black bars are detected now,
+// the returned note says what was found
+func transformSettings(file string, duration float64, width int, height int) (VideoTransform, string, error) {
+	t := VideoTransform{Rotation: int(videoRotation), FlipH: flipHorizontal, FlipV: flipVertical}
+	switch cropMode {
+	case cropAuto:
+		cropDetecting = true
+		crop, err := detectCrop(file, duration)
+		cropDetecting = false
+		if err != nil {
+			return t, "", err
+		}
+		if crop.Width >= width && crop.Height >= height {
+			return t, "No black bars found", nil
+		}
+		t.Crop = crop
+		return t, fmt.Sprintf("Cropped black bars, kept %dx%d at %d,%d", crop.Width, crop.Height, crop.X, crop.Y), nil
+	case cropManual:
+		crop, err := parseCrop(strCrop, width, height)
+		if err != nil {
+			return t, "", err
+		}
+		t.Crop = crop
+	}
+	return t, "", nil
+}
+
+// Fills in the manual crop with the detected black bars so they can be checked before encoding
+func suggestCrop() {
+	refreshAudioTracks()
+	cropDetecting = true
+	crop, err := detectCrop(filePath, selectedDuration)
+	cropDetecting = false
+	if err != nil {
+		cropStatus = err.Error()
+		return
+	}
+	strCrop = crop.String()
+	cropMode = cropManual
+	cropStatus = ""
+}
+
+func transformLayout() g.Widget {
+	return g.Layout{
+		g.Row(
+			g.Label("Crop"),
+			g.Combo("##CropMode", cropModeNames[cropMode], cropModeNames, &cropMode).Size(85),
+			g.Tooltip("Crop tip").Layout(
+				g.BulletText("Auto finds black bars on sampled frames and crops them off before encoding"),
+				g.BulletText("Manual crops to width:height:x:y, measured on the video the way it plays"),
+				g.BulletText("Cropping leaves more of the bitrate for the picture that's left"),
+			),
+			g.Condition(cropMode == cropManual, g.Layout{
+				g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+					g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+						g.InputText(&strCrop).Hint("w:h:x:y").Size(130),
+					),
+				),
+			}, g.Layout{}),
+			g.Button("Detect").OnClick(func() {
+				if !cropDetecting && filePath != "" {
+					go suggestCrop()
+				}
+			}),
+			g.Condition(cropDetecting, g.Label("Detecting..."), g.Label(cropStatus)),
+		),
+		g.Row(
+			g.Label("Rotate"),
+			g.Combo("##Rotation", rotationNames[videoRotation], rotationNames, &videoRotation).Size(100),
+			g.Checkbox("Flip Horizontal", &flipHorizontal),
+			g.Checkbox("Flip Vertical", &flipVertical),
+			g.Tooltip("Rotate tip").Layout(
+				g.BulletText("Phone videos are already turned the right way from their rotation metadata"),
+				g.BulletText("Use this for videos that were recorded sideways or mirrored"),
+			),
+		),
+	}
+}
+
+// Row of settings for each hidden region, sizes are percent of the frame from the top left
+func maskLayout() g.Widget {
+	rows := g.Layout{
+		g.Row(
+			g.Label("Hide Regions"),
+			g.Button("Add Region").OnClick(func() {
+				maskRegions = append(maskRegions, MaskRegion{Mode: maskBlur, X: 75, Y: 0, Width: 25, Height: 20})
+			}),
+			g.Tooltip("Hide regions tip").Layout(
+				g.BulletText("Blurs, pixelates or blacks out parts of the video like usernames, party chat or DMs"),
+				g.BulletText("X, Y, W and H are percent of the frame measured from the top left corner"),
+				g.BulletText("From and To are seconds of the video, To 0 hides it until the end"),
+				g.BulletText("Regions are saved with presets so every clip of the same game is covered"),
+			),
+		),
+	}
+	for i := range maskRegions {
+		r := &maskRegions[i]
+		rows = append(rows, g.Row(
+			g.Combo(fmt.Sprintf("##MaskMode%d", i), maskModeNames[r.Mode], maskModeNames, &r.Mode).Size(90),
+			g.Label("X"), g.InputFloat(&r.X).Label(fmt.Sprintf("##MaskX%d", i)).Format("%.1f").Size(45),
+			g.Label("Y"), g.InputFloat(&r.Y).Label(fmt.Sprintf("##MaskY%d", i)).Format("%.1f").Size(45),
+			g.Label("W"), g.InputFloat(&r.Width).Label(fmt.Sprintf("##MaskW%d", i)).Format("%.1f").Size(45),
+			g.Label("H"), g.InputFloat(&r.Height).Label(fmt.Sprintf("##MaskH%d", i)).Format("%.1f").Size(45),
+			g.Label("From"), g.InputFloat(&r.Start).Label(fmt.Sprintf("##MaskStart%d", i)).Format("%.1f").Size(45),
+			g.Label("To"), g.InputFloat(&r.End).Label(fmt.Sprintf("##MaskEnd%d", i)).Format("%.1f").Size(45),
+			g.Button(fmt.Sprintf("Remove##MaskRemove%d", i)).OnClick(func() {
+				maskRegions = append(maskRegions[:i:i], maskRegions[i+1:]...)
+			}),
+		))
+	}
+	return rows
+}
+
+// Switches the audio converter format. Quality levels mean something different for each format,
+// so quality mode goes back to bitrate
+func selectAudioFormat(format int) {
+	audioCompression = format
+	if audioRateMode == audioRateQuality {
+		audioRateMode = audioRateBitrate
+	}
+}
+
+// Checks if the selected audio format can be encoded at a VBR quality level, Opus only takes a bitrate
+// and AAC needs libfdk_aac
+func selectedFormatHasQuality() bool {
+	format := audioFormats[audioCompression]
+	if format.QualityMin == format.QualityMax {
+		return false
 	}
-	// Parse bitrate string
-	audioBitrate, err := strconv.ParseFloat(strAudioBitrate, 32)
-	if err != nil {
-		log.Println("Error parsing audio information: ", err)
-		encodingNow = false
-		audioEncodingNow = false
-		return
+	return format.hasQuality(audioFormatEncoders[audioCompression])
+}
+
+// Speed and effect controls shared by the video converter and animated image tabs
+func playbackLayout() g.Widget {
+	return g.Row(
+		g.Label("Speed"),
+		g.Combo("##PlaybackSpeed", playbackSpeedNames[playbackSpeed], playbackSpeedNames, &playbackSpeed).Size(75),
+		g.Label("Effect"),
+		g.Combo("##PlaybackEffect", playbackEffectNames[playbackEffect], playbackEffectNames, &playbackEffect).Size(100),
+		g.Tooltip("Playback tip").Layout(
+			g.BulletText("Speed changes the video and audio together, the audio keeps its pitch"),
+			g.BulletText("Reverse plays the clip backwards, Boomerang plays it forward then backward without audio"),
+			g.BulletText(fmt.Sprintf("Reverse and Boomerang only work on clips up to %d seconds", maxReverseSeconds)),
+		),
+	)
+}
+
+// Choices in the subtitle combo: none, each subtitle stream of the file, then a separate file
+func subtitleChoices() []string {
+	choices := []string{"None"}
+	for _, t := range subtitleTracks {
+		choices = append(choices, t.label())
 	}
+	return append(choices, "From File")
+}
 
-	// Encode the audio into a audio
-	audioEncode(filePath, float32(audioBitrate), audioCompression, duration)
+func subtitleFileChosen() bool {
+	return int(selectedSubtitle) == len(subtitleTracks)+1
+}
 
-	audioEncodingNow = false
-	encodingNow = false
-	encodingDone = true
-	beep.Alert("Discord Media Tool", "Audio Encoding Complete!", "")
+// Reads the subtitle settings shared by the video converter and animated image tabs.
+// offset is how many seconds of the source are skipped at the start of the output
+func subtitleSettings(source string, offset float64) (SubtitleOptions, error) {
+	subs := SubtitleOptions{Source: source, Track: -1, Offset: offset}
+	switch {
+	case selectedSubtitle == 0 || int(selectedSubtitle) > len(subtitleTracks)+1:
+	case subtitleFileChosen():
+		if _, err := os.Stat(subtitleFile); err != nil {
+			return subs, errors.New("select the subtitle file to burn in")
+		}
+		if !slices.Contains(subtitleFileExtensions, strings.ToLower(strings.TrimPrefix(filepath.Ext(subtitleFile), "."))) {
+			return subs, errors.New("subtitle files must be .srt, .ass, .ssa or .vtt")
+		}
+		subs.Path = subtitleFile
+	default:
+		track := subtitleTracks[selectedSubtitle-1]
+		subs.Track, subs.Bitmap = track.Number, track.bitmap()
+	}
+	return subs, nil
 }
 
-func beginGifConvert() {
-	encodingNow = true
-	getMediaInfo(filePath, "video")
-	/*
-		mediaInfo := getMediaInfo(filePath, "video")
-		if invalidFile {
-			log.Println("Aborting encode due to file error")
-			encodingNow = false
-			return
+func subtitleLayout() g.Widget {
+	choices := subtitleChoices()
+	preview := "None"
+	if int(selectedSubtitle) < len(choices) {
+		preview = choices[selectedSubtitle]
+	}
+	return g.Row(
+		g.Label("Subtitles"),
+		g.Combo("##Subtitles", preview, choices, &selectedSubtitle).Size(200),
+		g.Tooltip("Subtitles tip").Layout(
+			g.BulletText("Burns the subtitles into the picture, Discord doesn't show subtitle tracks"),
+			g.BulletText("Text subtitles keep their styling, picture subtitles (PGS, DVD) are drawn over the video"),
+			g.BulletText("From File uses a .srt, .ass, .ssa or .vtt file timed to the selected video"),
+		),
+		g.Condition(subtitleFileChosen(), g.Layout{
+			g.Button("Select Subtitles...").OnClick(func() {
+				filename, err := dialog.File().Filter("Subtitles", subtitleFileExtensions...).Title("Select a Subtitle File").Load()
+				if err != nil {
+					log.Println(err)
+					return
+				}
+				subtitleFile = strings.ReplaceAll(filename, `\`, "/")
+			}),
+			g.Label(subtitleFileLabel()),
+		}, g.Layout{}),
+	)
+}
+
+func subtitleFileLabel() string {
+	if subtitleFile == "" {
+		return "No file selected"
+	}
+	return filepath.Base(subtitleFile)
+}
+
+// Reads the caption settings shared by the video converter and animated image tabs
+func captionSettings() (CaptionOptions, error) {
+	c := CaptionOptions{
+		TopText:    strCaptionTop,
+		BottomText: strCaptionBottom,
+		Style:      int(captionStyle),
+		Font:       int(captionFont),
+		Size:       int(captionSize),
+		Outline:    int(captionOutline),
+	}
+	var err error
+	if strings.TrimSpace(strCaptionStart) != "" {
+		c.Start, err = strconv.ParseFloat(strCaptionStart, 64)
+		if err != nil || c.Start < 0 {
+			return c, errors.New("caption start must be a number of seconds")
 		}
-	*/
-	gifConvert(filePath)
+	}
+	if strings.TrimSpace(strCaptionEnd) != "" {
+		c.End, err = strconv.ParseFloat(strCaptionEnd, 64)
+		if err != nil || c.End < 0 {
+			return c, errors.New("caption end must be a number of seconds, or empty for the whole video")
+		}
+	}
+	return c, c.validate()
+}
+
+func captionLayout() g.Widget {
+	return g.Layout{
+		g.Row(
+			g.Label("Top Text"),
+			g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+				g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+					g.InputText(&strCaptionTop).Hint("none").Size(200),
+				),
+			),
+			g.Combo("##CaptionStyle", captionStyleNames[captionStyle], captionStyleNames, &captionStyle).Size(110),
+			g.Tooltip("Caption tip").Layout(
+				g.BulletText("Overlay draws white text with a black outline over the video"),
+				g.BulletText("Caption Bar adds a white bar above the video with the top text in black"),
+				g.BulletText("The bar makes the video taller, it is shrunk if needed to stay in the platform limits"),
+			),
+		),
+		g.Row(
+			g.Label("Bottom Text"),
+			g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+				g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+					g.InputText(&strCaptionBottom).Hint("none").Size(200),
+				),
+			),
+			g.Combo("##CaptionFont", captionFontNames[captionFont], captionFontNames, &captionFont).Size(130),
+		),
+		g.Condition(strings.TrimSpace(strCaptionTop) != "" || strings.TrimSpace(strCaptionBottom) != "", g.Layout{
+			g.Row(
+				g.SliderInt(&captionSize, 3, 25).Label("##CaptionSize").Format("Size %d%%").Size(110),
+				g.SliderInt(&captionOutline, 0, 10).Label("##CaptionOutline").Format("Outline %d").Size(110),
+				g.Label("From"),
+				g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+					g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+						g.InputText(&strCaptionStart).Hint("start").Size(50),
+					),
+				),
+				g.Label("To"),
+				g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+					g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+						g.InputText(&strCaptionEnd).Hint("end").Size(50),
+					),
+				),
+				g.Tooltip("Caption size tip").Layout(
+					g.BulletText("Size is the height of the text in percent of the video height"),
+					g.BulletText("From and To are seconds of the output, leave them empty to show the text the whole time"),
+				),
+			),
+		}, g.Layout{}),
+	}
 }
 
 func loop() {
@@ -192,7 +1394,23 @@ func loop() {
 	} else {
 		progressNum = progressTemp[0]
 	}
-	if encodingNow && encodingFirstPass && videoCompression == 1 {
+	if encodingNow && autoCodecStatus != "" {
+		g.PopupModal("Encoding Status").Flags(g.WindowFlagsNoMove|g.WindowFlagsNoResize).Layout(
+			g.Label("Status: "+autoCodecStatus),
+			g.Label("Progress: "+progressNum),
+		).Build()
+		g.OpenPopup("Encoding Status")
+	} else if encodingNow && attemptStatus != "" {
+		g.PopupModal("Encoding Status").Flags(g.WindowFlagsNoMove | g.WindowFlagsNoResize).Layout(
+			g.Label("Status: " + attemptStatus),
+		).Build()
+		g.OpenPopup("Encoding Status")
+	} else if encodingNow && loudnessNow {
+		g.PopupModal("Encoding Status").Flags(g.WindowFlagsNoMove | g.WindowFlagsNoResize).Layout(
+			g.Label("Status: Measuring loudness"),
+		).Build()
+		g.OpenPopup("Encoding Status")
+	} else if encodingNow && encodingFirstPass && encodingCodec == codecVP9 {
 		g.PopupModal("Encoding Progress: VP9 Analysis").Flags(g.WindowFlagsNoMove | g.WindowFlagsNoResize).Layout(
 			g.Label("Status: Analyzing File\nProgress: VP9 doesn't analysis progress"),
 		).Build()
@@ -209,6 +1427,12 @@ func loop() {
 			g.Label("Progress: "+progressNum),
 		).Build()
 		g.OpenPopup("Encoding Status")
+	} else if encodingNow && scoringNow {
+		g.PopupModal("Encoding Status").Flags(g.WindowFlagsNoMove|g.WindowFlagsNoResize).Layout(
+			g.Label("Status: Scoring quality"),
+			g.Label("Progress: "+progressNum),
+		).Build()
+		g.OpenPopup("Encoding Status")
 	} else if encodingNow && audioEncodingNow {
 		g.PopupModal("Audio Encoding Status").Flags(g.WindowFlagsNoMove | g.WindowFlagsNoResize).Layout(
 			g.Label("Encoding Progress: " + progressNum + "                 "),
@@ -221,10 +1445,31 @@ func loop() {
 		g.OpenPopup("Encoding Status")
 	}
 
+	// Shows the metadata of the selected file
+	if metadataViewOpen {
+		var metadataLabels g.Layout
+		for _, line := range metadataLines {
+			metadataLabels = append(metadataLabels, g.Label(line))
+		}
+		g.PopupModal("Metadata").Flags(g.WindowFlagsNoMove|g.WindowFlagsNoResize).Layout(
+			metadataLabels,
+			g.Button("Close").OnClick(func() {
+				metadataViewOpen = false
+				g.CloseCurrentPopup()
+			}),
+		).Build()
+		g.OpenPopup("Metadata")
+	}
+
 	// Shows after encoding is complete
 	if encodingDone {
+		var resultLabels g.Layout
+		for _, line := range lastResult.summary() {
+			resultLabels = append(resultLabels, g.Label(line))
+		}
 		g.PopupModal("Encoding Status ").Flags(g.WindowFlagsNoMove|g.WindowFlagsNoResize).Layout(
 			g.Label("Encoding finished!"),
+			resultLabels,
 			g.Button("Close").OnClick(func() {
 				progressStr = "Starting" // reset progress
 				encodingDone = false
@@ -246,36 +1491,401 @@ func loop() {
 		g.OpenPopup("File Error")
 	}
 
-	// Shows up if something goes wrong when the user tries to encode something
-	// - Invalid size
-	if encodeError {
-		g.PopupModal("Encode Error").Flags(g.WindowFlagsNoMove|g.WindowFlagsNoResize).Layout(
-			g.Label("FFmpeg encountered an error while encoding."),
-			g.Button("Close").OnClick(func() {
-				encodeError = false
-				g.CloseCurrentPopup()
-			}),
-		).Build()
-		g.OpenPopup("Encode Error")
-	}
+	// Shows when a setting in the GUI can't be used
+	if settingsError != "" {
+		g.PopupModal("Settings Error").Flags(g.WindowFlagsNoMove|g.WindowFlagsNoResize).Layout(
+			g.Label(settingsError),
+			g.Button("Close").OnClick(func() {
+				settingsError = ""
+				g.CloseCurrentPopup()
+			}),
+		).Build()
+		g.OpenPopup("Settings Error")
+	}
+
+	// Shows up if something goes wrong when the user tries to encode something
+	// - Invalid size
+	if encodeError {
+		g.PopupModal("Encode Error").Flags(g.WindowFlagsNoMove|g.WindowFlagsNoResize).Layout(
+			g.Label("FFmpeg encountered an error while encoding."),
+			g.Button("Close").OnClick(func() {
+				encodeError = false
+				g.CloseCurrentPopup()
+			}),
+		).Build()
+		g.OpenPopup("Encode Error")
+	}
+
+	// Main GUI window
+
+	g.SingleWindow().Layout(
+		// Video Compressor UI
+		g.TabBar().TabItems(
+			g.TabItem("Video Converter").Layout(
+
+				// File selection
+				g.Label("Video File"),
+				g.Row(
+					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+							g.InputText(&filePath),
+						),
+					),
+					g.Tooltip("Video Selection").Layout(
+						g.Label("The video or audio file to compress into a video file"),
+					),
+					g.Button("Select...").OnClick(func() {
+						filename, err := dialog.File().Title("Select a File").Load()
+						if err != nil {
+							log.Println(err)
+						}
+						log.Println("Selected file:", filename)
+						filePath = strings.ReplaceAll(filename, `\`, "/")
+						go loadAudioTracks(filePath)
+					}),
+				),
+
+				// Codec selection
+				g.Label("Video Codec"),
+				g.Row(
+					g.RadioButton("H264 (.mp4)", videoCompression == codecH264).OnChange(func() {
+						videoCompression = codecH264
+					}),
+					g.Tooltip("h264 tip").Layout(
+						g.BulletText("Average quality"),
+						g.BulletText("Decent conversion speed"),
+						g.BulletText("Near universal compatibility"),
+					),
+
+					g.RadioButton("VP9 (.webm)", videoCompression == codecVP9).OnChange(func() {
+						videoCompression = codecVP9
+					}),
+					g.Tooltip("VP9 tip").Layout(
+						g.BulletText("Better quality than H264"),
+						g.BulletText("Takes longer to encode"),
+						g.BulletText("Discord won't natively play these videos on iOS devices"),
+					),
+
+					// HEVC and AV1 are only listed when the FFmpeg build has their encoders
+					g.Condition(videoCodecAvailable[codecHEVC], g.Layout{
+						g.RadioButton("HEVC (.mp4)", videoCompression == codecHEVC).OnChange(func() {
+							videoCompression = codecHEVC
+						}),
+						g.Tooltip("HEVC tip").Layout(
+							g.BulletText("Better quality than H264 at the same size"),
+							g.BulletText("Plays on iPhones but not in every browser"),
+						),
+					}, g.Layout{}),
+					g.Condition(videoCodecAvailable[codecAV1], g.Layout{
+						g.RadioButton("AV1 (.mp4)", videoCompression == codecAV1).OnChange(func() {
+							videoCompression = codecAV1
+						}),
+						g.Tooltip("AV1 tip").Layout(
+							g.BulletText("The best quality at the same size"),
+							g.BulletText("Very slow to encode and only recent iPhones can play it"),
+						),
+					}, g.Layout{}),
+
+					g.RadioButton("Auto", videoCompression == codecAuto).OnChange(func() {
+						videoCompression = codecAuto
+					}),
+					g.Tooltip("Auto tip").Layout(
+						g.BulletText("Encodes short samples with H264, VP9, HEVC and AV1 and keeps the best looking one"),
+						g.BulletText("HEVC and AV1 are only tried if your FFmpeg build supports them"),
+						g.BulletText("Testing the samples adds time before the actual encode"),
+					),
+				),
+				g.Condition(videoCompression == codecAuto,
+					g.Row(
+						g.Checkbox("Must play on iOS", &requireIOSPlayback),
+						g.Tooltip("iOS tip").Layout(
+							g.BulletText("Skips VP9 and AV1 since Discord on iOS can't play them"),
+						),
+					),
+					g.Layout{},
+				),
+
+				// Presets
+				g.Label("Preset"),
+				g.Row(
+					g.Combo("##Preset", presetPreview(), presetNames(), &selectedPreset).Size(200).OnChange(func() {
+						applyPreset(allPresets()[selectedPreset])
+					}),
+					g.Tooltip("Preset tip").Layout(
+						g.BulletText("Fills in the target size, codec, audio bitrate and max resolution"),
+						g.BulletText("You can still change any setting after picking a preset"),
+					),
+					g.Button("Import...").OnClick(func() {
+						filename, err := dialog.File().Filter("DMT Preset", "json").Title("Import Preset").Load()
+						if err != nil {
+							log.Println(err)
+							return
+						}
+						p, err := importPreset(filename)
+						if err != nil {
+							settingsError = "Couldn't import preset: " + err.Error()
+							return
+						}
+						applyPreset(p)
+						selectPreset(p.Name)
+					}),
+					g.Button("Export...").OnClick(func() {
+						p, err := presetFromSettings(strPresetName)
+						if err != nil {
+							settingsError = "Couldn't export preset: " + err.Error()
+							return
+						}
+						filename, err := dialog.File().Filter("DMT Preset", "json").Title("Export Preset").Save()
+						if err != nil {
+							log.Println(err)
+							return
+						}
+						if filepath.Ext(filename) == "" {
+							filename += ".json"
+						}
+						err = exportPreset(p, filename)
+						if err != nil {
+							settingsError = "Couldn't export preset: " + err.Error()
+						}
+					}),
+				),
+
+				// Target File Size
+				g.Label("Target File Size"),
+				g.Row(
+					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+							g.InputText(&strTargetSize).Size(75),
+						),
+					),
+					g.Tooltip("Target").Layout(
+						g.BulletText("Up to 10 MB limit for non-nitro"),
+						g.BulletText("Up to 50 MB limit for nitro classic"),
+						g.BulletText("Up to 500 MB limit for nitro"),
+					),
+					g.Combo("##Unit", sizeUnitNames[targetSizeUnit], sizeUnitNames, &targetSizeUnit).Size(60),
+					g.Tooltip("Unit tip").Layout(
+						g.BulletText("MB and KB are decimal (1 MB = 1,000,000 bytes)"),
+						g.BulletText("MiB and KiB are binary (1 MiB = 1,048,576 bytes)"),
+					),
+					g.Combo("##Platform", platformNames[selectedPlatform], platformNames, &selectedPlatform).Size(90),
+					g.Tooltip("Platform tip").Layout(
+						g.BulletText("Where the video will be posted"),
+						g.BulletText("The size, length, resolution and codec are kept within that site's limits"),
+						g.BulletText("Anything that had to change is listed when encoding finishes"),
+					),
+					g.Checkbox("Convervative Bitrate", &conservativeBitrate),
+					g.Tooltip("Conservative").Layout(
+						g.BulletText("After calculating the bitrate, reduce the bitrate slightly."),
+						g.BulletText("Enabled by default since this can help with the target file size"),
+					),
+					/* g.Checkbox("Strict Mode", &fsArgument),
+					g.Tooltip("Strict").Layout(
+						g.Label("Stops encoding if the file size reaches the target amount before"),
+						g.Label("finishing the encode, which can cause the duration of the output"),
+						g.Label("to be smaller."),
+						g.Label(""),
+						g.Label("Enable this if the compressed file is larger than the specified"),
+						g.Label("target size without this checked, which normally shouldn't happen."),
+					), */
+				),
+
+				g.Label("Exact limit: "+targetSizeLabel()),
+
+				// Max resolution and audio bitrate
+				g.Row(
+					g.Label("Max Resolution"),
+					g.Combo("##Resolution", resolutionNames[maxResolution], resolutionNames, &maxResolution).Size(85),
+					g.Tooltip("Resolution tip").Layout(
+						g.BulletText("Downscales larger videos so the shorter side fits this size"),
+						g.BulletText("Lower resolutions look sharper when the target size is small"),
+					),
+				),
+
+				// Audio codec, bitrate and channels inside the video
+				g.Row(
+					g.Label("Audio"),
+					g.Combo("##VideoAudioCodec", videoAudioCodecNames[videoAudioCodec], videoAudioCodecNames, &videoAudioCodec).Size(85),
+					g.Tooltip("Video audio tip").Layout(
+						g.BulletText("Opus sounds better at low bitrates"),
+						g.BulletText("AAC plays on older players and in Discord's iOS preview"),
+						g.BulletText("No Audio gives the whole target size to the video"),
+					),
+					g.Condition(videoAudioCodec != videoAudioNone, g.Layout{
+						g.Checkbox("Auto", &autoAudioBitrate),
+						g.Tooltip("Auto audio tip").Layout(
+							g.BulletText("Picks the audio bitrate and mono or stereo from the target size"),
+							g.BulletText("Small targets leave more of the size to the video"),
+						),
+					}, g.Layout{}),
+					g.Condition(videoAudioCodec != videoAudioNone && autoAudioBitrate, g.Layout{
+						g.Combo("##AudioContent", audioContentNames[audioContent], audioContentNames, &audioContent).Size(85),
+						g.Tooltip("Content tip").Layout(
+							g.BulletText("Speech uses low bitrate mono, music gets the most bitrate"),
+						),
+					}, g.Layout{}),
+					g.Condition(videoAudioCodec != videoAudioNone && !autoAudioBitrate, g.Layout{
+						g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+							g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+								g.InputText(&strVideoAudioBitrate).Size(40),
+							),
+						),
+						g.Label("Kb/s"),
+						g.Checkbox("Mono", &videoAudioMono),
+						g.Tooltip("Mono tip").Layout(
+							g.BulletText("Downmixes to one channel, fine for voice and saves bitrate"),
+						),
+					}, g.Layout{}),
+				),
+				g.Condition(videoAudioCodec != videoAudioNone && autoAudioBitrate,
+					g.Label(audioAllocationPreview()),
+					g.Layout{},
+				),
+
+				// Saving the current settings as a preset
+				g.Row(
+					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+							g.InputText(&strPresetName).Hint("Preset name").Size(150),
+						),
+					),
+					g.Button("Save Preset").OnClick(func() {
+						p, err := presetFromSettings(strPresetName)
+						if err == nil {
+							err = storeUserPreset(p)
+						}
+						if err != nil {
+							settingsError = "Couldn't save preset: " + err.Error()
+							return
+						}
+						selectPreset(p.Name)
+					}),
+					g.Tooltip("Save tip").Layout(
+						g.BulletText("Saves the current settings under this name"),
+						g.BulletText("Saving with the name of one of your presets updates it"),
+					),
+					g.Button("Delete Preset").OnClick(func() {
+						err := deleteUserPreset(strPresetName)
+						if err != nil {
+							settingsError = "Couldn't delete preset: " + err.Error()
+							return
+						}
+						selectedPreset = 0
+						applyPreset(allPresets()[0])
+					}),
+				),
+
+				// Post-encode quality scoring
+				g.Row(
+					g.Checkbox("Quality Score", &qualityScoring),
+					g.Tooltip("Quality").Layout(
+						g.BulletText("Compares the compressed video against the original after encoding"),
+						g.BulletText("VMAF needs an FFmpeg build with libvmaf, SSIM is used otherwise"),
+						g.BulletText("Takes extra time after the encode finishes"),
+					),
+					g.Combo("##Metric", qualityMetricNames[qualityMetric], qualityMetricNames, &qualityMetric).Size(75),
+					g.Checkbox("Save Result JSON", &saveResultJSON),
+					g.Tooltip("Result JSON").Layout(
+						g.BulletText("Writes a .json file with the encode results next to the output"),
+					),
+				),
+
+				// Replace or overlay the audio with another file
+				g.Row(
+					g.Label("Audio Source"),
+					g.Combo("##AddedAudio", addedAudioModeNames[addedAudioMode], addedAudioModeNames, &addedAudioMode).Size(85),
+					g.Tooltip("Audio source tip").Layout(
+						g.BulletText("Replace swaps the video's audio for another audio file"),
+						g.BulletText("Overlay mixes another audio file under the video's audio, like music over gameplay"),
+						g.BulletText("The added audio is cut at the end of the video"),
+					),
+					g.Condition(addedAudioMode != addedAudioOff, g.Layout{
+						g.Button("Select Audio...").OnClick(func() {
+							filename, err := dialog.File().Title("Select an Audio File").Load()
+							if err != nil {
+								log.Println(err)
+								return
+							}
+							addedAudioPath = strings.ReplaceAll(filename, `\`, "/")
+						}),
+						g.Label(addedAudioLabel()),
+					}, g.Layout{}),
+				),
+				g.Condition(addedAudioMode != addedAudioOff,
+					g.Row(
+						g.Checkbox("Loop", &addedAudioLoop),
+						g.Tooltip("Loop tip").Layout(
+							g.BulletText("Repeats the audio file if it's shorter than the video"),
+							g.BulletText("Unchecked, the rest of the video is silent"),
+						),
+						g.Label("Volume"),
+						g.SliderInt(&addedAudioVolumes[addedAudioMode], 0, 200).Label("##AddedVolume").Format("%d%%").Size(150),
+					),
+					g.Layout{},
+				),
+
+				// Audio track selection
+				audioTracksLayout(),
+
+				// Loudness normalization
+				loudnessRow(),
+
+				// Metadata policy
+				metadataRow(),
+
+				// Clips to join into one video
+				concatLayout(),
 
-	// Main GUI window
+				// Crop, rotate and flip
+				transformLayout(),
 
-	g.SingleWindow().Layout(
-		// Video Compressor UI
-		g.TabBar().TabItems(
-			g.TabItem("Video Converter").Layout(
+				// Blurred, pixelated or blacked out regions
+				maskLayout(),
 
-				// File selection
-				g.Label("Video File"),
+				// Speed and effects
+				playbackLayout(),
+
+				// Burned in subtitles
+				subtitleLayout(),
+
+				// Caption text
+				captionLayout(),
+
+				// Compress button
+				g.Label("\n\n\n"),
+				g.Align(g.AlignCenter).To(
+					g.Button("Compress").Size(125, 30).OnClick(func() {
+						dependencyCheck()
+						if ffmpegNotFound || ffprobeNotFound {
+							return
+						}
+						if encodingDone {
+							return
+						} else {
+							invalidFile = false
+							if concatMode {
+								go beginConcatEncode()
+							} else {
+								go beginEncode() // go routine to avoid blocking giu main thread
+							}
+						}
+					}),
+				),
+			),
+
+			// Audio converter GUI
+			g.TabItem("Audio Converter").Layout(
+
+				// File Selection
+				g.Label("Audio File"),
 				g.Row(
 					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
 						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
 							g.InputText(&filePath),
 						),
 					),
-					g.Tooltip("Video Selection").Layout(
-						g.Label("The video or audio file to compress into a video file"),
+					g.Tooltip("Audio Selection").Layout(
+						g.Label("The audio or video file to compress into a audio file"),
 					),
 					g.Button("Select...").OnClick(func() {
 						filename, err := dialog.File().Title("Select a File").Load()
@@ -284,62 +1894,215 @@ func loop() {
 						}
 						log.Println("Selected file:", filename)
 						filePath = strings.ReplaceAll(filename, `\`, "/")
+						go loadAudioTracks(filePath)
 					}),
 				),
 
-				// Codec selection
-				g.Label("Video Codec"),
 				g.Row(
-					g.RadioButton("H264 (.mp4)", videoCompression == 0).OnChange(func() {
-						videoCompression = 0
+					g.Checkbox("Discord Voice Message", &voiceMessage).OnChange(func() {
+						audioToVideo = false
 					}),
-					g.Tooltip("h264 tip").Layout(
-						g.BulletText("Average quality"),
-						g.BulletText("Decent conversion speed"),
-						g.BulletText("Near universal compatibility"),
+					g.Tooltip("voice message tip").Layout(
+						g.BulletText("Mono 48 kHz Opus in an .ogg file, the format Discord voice messages use"),
+						g.BulletText("Also writes a .json file with duration_secs and waveform for bots to attach"),
 					),
-
-					g.RadioButton("VP9 (.webm)", videoCompression == 1).OnChange(func() {
-						videoCompression = 1
+					g.Checkbox("Audio to Video", &audioToVideo).OnChange(func() {
+						voiceMessage = false
 					}),
-					g.Tooltip("VP9 tip").Layout(
-						g.BulletText("Better quality than H264"),
-						g.BulletText("Takes longer to encode"),
-						g.BulletText("Discord won't natively play these videos on iOS devices"),
+					g.Tooltip("audio to video tip").Layout(
+						g.BulletText("Makes an MP4 with a picture or visualization so Discord shows a player"),
+						g.BulletText("Nearly all of the target size goes to the audio"),
 					),
 				),
+				g.Condition(audioToVideo, audioVideoLayout(), g.Layout{}),
+				g.Condition(!voiceMessage && !audioToVideo, g.Layout{
+					// Audio codec selection
+					g.Label("Audio Codec"),
+					g.Row(
+						g.RadioButton("MP3 (.mp3)", audioCompression == audioMP3).OnChange(func() {
+							selectAudioFormat(audioMP3)
+						}),
+						g.Tooltip("mp3 tip").Layout(
+							g.BulletText("MP3 files will probably play on anything with a speaker"),
+						),
 
-				// Target File Size
-				g.Label("Target File Size"),
+						g.RadioButton("Opus (.opus)", audioCompression == audioOpus).OnChange(func() {
+							selectAudioFormat(audioOpus)
+						}),
+						g.Tooltip("opus tip").Layout(
+							g.BulletText("Better quality at even lower bitrates compared to mp3"),
+							g.BulletText("Will play on most modern devices"),
+						),
+
+						g.RadioButton("AAC (.m4a)", audioCompression == audioAAC).OnChange(func() {
+							selectAudioFormat(audioAAC)
+						}),
+						g.Tooltip("aac tip").Layout(
+							g.BulletText("Plays natively on iPhones and other Apple devices"),
+							g.BulletText("Uses libfdk_aac if your FFmpeg build has it"),
+						),
+					),
+					g.Row(
+						g.RadioButton("Vorbis (.ogg)", audioCompression == audioVorbis).OnChange(func() {
+							selectAudioFormat(audioVorbis)
+						}),
+						g.Tooltip("vorbis tip").Layout(
+							g.BulletText("Open format that's a bit better than mp3 at the same bitrate"),
+						),
+
+						g.RadioButton("FLAC (.flac)", audioCompression == audioFLAC).OnChange(func() {
+							selectAudioFormat(audioFLAC)
+						}),
+						g.Tooltip("flac tip").Layout(
+							g.BulletText("Lossless, keeps the audio exactly as it is"),
+							g.BulletText("Files are much larger than lossy formats"),
+						),
+
+						g.RadioButton("WAV (.wav)", audioCompression == audioWAV).OnChange(func() {
+							selectAudioFormat(audioWAV)
+						}),
+						g.Tooltip("wav tip").Layout(
+							g.BulletText("Uncompressed 16-bit audio, the largest files but opens in any editor"),
+						),
+					),
+
+					// Bitrate selection
+					g.Condition(audioFormats[audioCompression].Lossless,
+						g.Condition(audioCompression == audioFLAC,
+							g.Row(
+								g.Label("Compression Level"),
+								g.SliderInt(&flacCompression, 0, 12).Size(150),
+								g.Tooltip("flac level tip").Layout(
+									g.BulletText("Higher levels make slightly smaller files but take longer"),
+									g.BulletText("The audio is identical at every level"),
+								),
+							),
+							g.Label("WAV files are not compressed"),
+						),
+						g.Layout{
+							g.Row(
+								g.RadioButton("Bitrate", audioRateMode == audioRateBitrate).OnChange(func() {
+									audioRateMode = audioRateBitrate
+								}),
+								g.Condition(selectedFormatHasQuality(), g.Layout{
+									g.RadioButton("Quality (VBR)", audioRateMode == audioRateQuality).OnChange(func() {
+										audioRateMode = audioRateQuality
+										strAudioQuality = strconv.FormatFloat(float64(audioFormats[audioCompression].QualityDefault), 'f', -1, 32)
+									}),
+									g.Tooltip("Audio quality tip").Layout(
+										g.BulletText("MP3: 0 (best) to 9 (smallest)"),
+										g.BulletText("Vorbis: -1 (smallest) to 10 (best)"),
+										g.BulletText("AAC: 1 (smallest) to 5 (best), needs libfdk_aac"),
+									),
+								}, g.Layout{}),
+								g.RadioButton("Target File Size", audioRateMode == audioRateTarget).OnChange(func() {
+									audioRateMode = audioRateTarget
+								}),
+								g.Tooltip("Audio target tip").Layout(
+									g.BulletText("Picks the highest bitrate that keeps the file under the target size"),
+									g.BulletText("You'll get a warning if the bitrate is too low to sound good"),
+								),
+							),
+							g.Row(
+								g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+									g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+										g.Condition(audioRateMode == audioRateTarget,
+											g.InputText(&strAudioTargetSize).Size(75),
+											g.Condition(audioRateMode == audioRateQuality,
+												g.InputText(&strAudioQuality).Size(75),
+												g.InputText(&strAudioBitrate).Size(75),
+											),
+										),
+									),
+								),
+								g.Condition(audioRateMode == audioRateTarget,
+									g.Combo("##AudioUnit", sizeUnitNames[audioTargetUnit], sizeUnitNames, &audioTargetUnit).Size(60),
+									g.Condition(audioRateMode == audioRateQuality,
+										g.Label("Quality"),
+										g.Label("Kb/s"),
+									),
+								),
+							),
+						},
+					),
+				}, g.Condition(voiceMessage, g.Label(fmt.Sprintf("Opus, mono, 48 kHz, %d Kb/s", voiceBitrate)), g.Layout{})),
+
+				// Audio track selection
+				audioTracksLayout(),
+
+				// Loudness normalization
+				loudnessRow(),
+
+				// Metadata policy
+				metadataRow(),
+
+				g.Label("\n\n\n"),
+				g.Align(g.AlignCenter).To(
+					g.Button("Compress").Size(125, 30).OnClick(func() {
+						dependencyCheck()
+						if ffmpegNotFound || ffprobeNotFound {
+							return
+						}
+						if encodingDone {
+							return
+						} else {
+							invalidFile = false
+							go beginAudioConvert()
+						}
+					}),
+				),
+			),
+
+			// Image converter GUI
+			g.TabItem("Image Converter").Layout(
+
+				// File Selection
+				g.Label("Image File"),
 				g.Row(
 					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
 						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
-							g.InputText(&strTargetSize).Size(75),
+							g.InputText(&filePath),
 						),
 					),
-					g.Tooltip("Target").Layout(
-						g.BulletText("Up to 10 MB limit for non-nitro"),
-						g.BulletText("Up to 50 MB limit for nitro classic"),
-						g.BulletText("Up to 500 MB limit for nitro"),
+					g.Tooltip("Image Selection").Layout(
+						g.Label("The image to compress"),
 					),
-					g.Label("MB"),
-					g.Checkbox("Convervative Bitrate", &conservativeBitrate),
-					g.Tooltip("Conservative").Layout(
-						g.BulletText("After calculating the bitrate, reduce the bitrate slightly."),
-						g.BulletText("Enabled by default since this can help with the target file size"),
+					g.Button("Select...").OnClick(func() {
+						filename, err := dialog.File().Filter("Images", "png", "jpg", "jpeg", "webp", "bmp", "tiff", "avif").Title("Select an Image").Load()
+						if err != nil {
+							log.Println(err)
+						}
+						log.Println("Selected file:", filename)
+						filePath = strings.ReplaceAll(filename, `\`, "/")
+					}),
+				),
+
+				// Format, max size and target size
+				g.Row(
+					g.Label("Format"),
+					g.Combo("##ImageFormat", imageFormatNames[imageFormat], imageFormatNames, &imageFormat).Size(75),
+					g.Tooltip("Image format tip").Layout(
+						g.BulletText("JPEG opens everywhere"),
+						g.BulletText("WebP is smaller than JPEG at the same quality and Discord shows it"),
+						g.BulletText("AVIF is the smallest but slow to encode and not every app opens it"),
+					),
+					g.Label("Max Size"),
+					g.Combo("##ImageMaxSize", imageMaxSizeNames[imageMaxSize], imageMaxSizeNames, &imageMaxSize).Size(85),
+					g.Tooltip("Image max size tip").Layout(
+						g.BulletText("Downscales the image so its longest side fits this size"),
 					),
-					/* g.Checkbox("Strict Mode", &fsArgument),
-					g.Tooltip("Strict").Layout(
-						g.Label("Stops encoding if the file size reaches the target amount before"),
-						g.Label("finishing the encode, which can cause the duration of the output"),
-						g.Label("to be smaller."),
-						g.Label(""),
-						g.Label("Enable this if the compressed file is larger than the specified"),
-						g.Label("target size without this checked, which normally shouldn't happen."),
-					), */
 				),
+				g.Row(
+					g.Label("Target Size"),
+					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+							g.InputText(&strImageTargetSize).Size(75),
+						),
+					),
+					g.Combo("##ImageUnit", sizeUnitNames[imageTargetUnit], sizeUnitNames, &imageTargetUnit).Size(60),
+				),
+				g.Label("The highest quality that fits the target size is found automatically.\nEXIF data like GPS location is removed, the image is kept the right way up."),
 
-				// Compress button
 				g.Label("\n\n\n"),
 				g.Align(g.AlignCenter).To(
 					g.Button("Compress").Size(125, 30).OnClick(func() {
@@ -351,25 +2114,25 @@ func loop() {
 							return
 						} else {
 							invalidFile = false
-							go beginEncode() // go routine to avoid blocking giu main thread
+							go beginImageConvert()
 						}
 					}),
 				),
 			),
 
-			// Audio converter GUI
-			g.TabItem("Audio Converter").Layout(
+			// Animated image GUI
+			g.TabItem("Animated Image").Layout(
 
 				// File Selection
-				g.Label("Audio File"),
+				g.Label("Video File"),
 				g.Row(
 					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
 						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
 							g.InputText(&filePath),
 						),
 					),
-					g.Tooltip("Audio Selection").Layout(
-						g.Label("The audio or video file to compress into a audio file"),
+					g.Tooltip("Animated Selection").Layout(
+						g.Label("The video or GIF to turn into an animated image"),
 					),
 					g.Button("Select...").OnClick(func() {
 						filename, err := dialog.File().Title("Select a File").Load()
@@ -378,41 +2141,166 @@ func loop() {
 						}
 						log.Println("Selected file:", filename)
 						filePath = strings.ReplaceAll(filename, `\`, "/")
+						go loadAudioTracks(filePath)
 					}),
 				),
 
-				// Audio codec selection
-				g.Label("Audio Codec"),
+				// Format, frame rate and size
+				g.Row(
+					g.Label("Format"),
+					g.Combo("##AnimatedFormat", animatedFormatNames[animatedFormat], animatedFormatNames, &animatedFormat).Size(75),
+					g.Tooltip("Animated format tip").Layout(
+						g.BulletText("WebP is the smallest and keeps full color, Discord shows it animated"),
+						g.BulletText("APNG keeps full color and transparency but is larger"),
+						g.BulletText("GIF plays everywhere but only has 256 colors and is the largest"),
+					),
+					g.Label("FPS"),
+					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+							g.InputText(&strAnimatedFPS).Size(40),
+						),
+					),
+					g.Label("Max Width"),
+					g.Combo("##AnimatedWidth", animatedWidthNames[animatedWidth], animatedWidthNames, &animatedWidth).Size(85),
+				),
+				g.Row(
+					g.Label("Plays"),
+					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+							g.InputText(&strAnimatedPlays).Size(40),
+						),
+					),
+					g.Tooltip("Plays tip").Layout(
+						g.BulletText("0 loops forever, otherwise how many times it plays before stopping"),
+					),
+					g.Label("Start"),
+					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+							g.InputText(&strAnimatedStart).Size(50),
+						),
+					),
+					g.Label("Length"),
+					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+							g.InputText(&strAnimatedLength).Hint("all").Size(50),
+						),
+					),
+					g.Label("seconds"),
+				),
+
+				// Size target
 				g.Row(
-					g.RadioButton("MP3 (.mp3)", audioCompression == 0).OnChange(func() {
-						audioCompression = 0
+					g.Checkbox("Target Size", &animatedTarget),
+					g.Tooltip("Animated target tip").Layout(
+						g.BulletText("Lowers the quality, then the frame rate, then the size until it fits"),
+						g.BulletText("Each try is a full encode so this can take a while"),
+					),
+					g.Condition(animatedTarget, g.Layout{
+						g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+							g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+								g.InputText(&strAnimatedTargetSize).Size(75),
+							),
+						),
+						g.Combo("##AnimatedUnit", sizeUnitNames[animatedTargetUnit], sizeUnitNames, &animatedTargetUnit).Size(60),
+					}, g.Layout{}),
+				),
+
+				// Speed and effects
+				playbackLayout(),
+
+				// Burned in subtitles
+				subtitleLayout(),
+
+				// Caption text
+				captionLayout(),
+
+				g.Label("\n\n\n"),
+				g.Align(g.AlignCenter).To(
+					g.Button("Convert").Size(125, 30).OnClick(func() {
+						dependencyCheck()
+						if ffmpegNotFound || ffprobeNotFound {
+							return
+						}
+						if encodingDone {
+							return
+						} else {
+							invalidFile = false
+							go beginAnimatedConvert()
+						}
 					}),
-					g.Tooltip("mp3 tip").Layout(
-						g.BulletText("MP3 files will probably play on anything with a speaker"),
+				),
+			),
+
+			// Discord asset generator GUI
+			g.TabItem("Assets").Layout(
+
+				// File Selection
+				g.Label("Image or Video File"),
+				g.Row(
+					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+							g.InputText(&filePath),
+						),
 					),
+					g.Tooltip("Asset Selection").Layout(
+						g.Label("The image or video to make the asset from"),
+					),
+					g.Button("Select...").OnClick(func() {
+						filename, err := dialog.File().Title("Select a File").Load()
+						if err != nil {
+							log.Println(err)
+						}
+						log.Println("Selected file:", filename)
+						filePath = strings.ReplaceAll(filename, `\`, "/")
+						go loadAudioTracks(filePath)
+					}),
+				),
 
-					g.RadioButton("Opus (.opus)", audioCompression == 1).OnChange(func() {
-						audioCompression = 1
+				// Asset type
+				g.Row(
+					g.Label("Asset"),
+					g.Combo("##Asset", assetNames[selectedAsset], assetNames, &selectedAsset).Size(125).OnChange(func() {
+						assetFormat = 0
 					}),
-					g.Tooltip("opus tip").Layout(
-						g.BulletText("Better quality at even lower bitrates compared to mp3"),
-						g.BulletText("Will play on most modern devices"),
+					g.Tooltip("Asset tip").Layout(
+						g.BulletText("Emoji: 128x128 under 256 KiB, PNG, GIF or WebP"),
+						g.BulletText("Sticker: 320x320 under 512 KiB, PNG or APNG"),
+						g.BulletText("Avatar: 512x512 under 10 MiB, PNG or GIF"),
+						g.BulletText("Server Banner: 960x540 under 10 MiB, PNG or GIF"),
 					),
+					g.Label(assetSpecLabel()),
 				),
-				// Bitrate selection
-				g.Label("Audio Bitrate"),
 				g.Row(
+					g.Checkbox("Animated", &assetAnimated),
+					g.Tooltip("Animated tip").Layout(
+						g.BulletText("Makes a GIF (or APNG for stickers) from a segment of a video"),
+						g.BulletText("Emoji can be an animated WebP instead, which usually looks better at the same size"),
+						g.BulletText("Unchecked, a single frame is taken at the start time"),
+					),
+					g.Condition(assetAnimated && len(assetSpecs[selectedAsset].Animated) > 1, g.Layout{
+						g.Combo("##AssetFormat", assetSpecs[selectedAsset].animatedNames()[assetFormat], assetSpecs[selectedAsset].animatedNames(), &assetFormat).Size(60),
+					}, g.Layout{}),
+					g.Label("Start"),
 					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
 						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
-							g.InputText(&strAudioBitrate).Size(75),
+							g.InputText(&strAssetStart).Size(50),
 						),
 					),
-					g.Label("Kb/s"),
+					g.Condition(assetAnimated, g.Layout{
+						g.Label("Length"),
+						g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+							g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+								g.InputText(&strAssetLength).Size(50),
+							),
+						),
+					}, g.Layout{}),
+					g.Label("seconds"),
 				),
+				g.Label("The source is cropped to fill the asset, then the frame rate and colors are\nlowered until it fits under the size limit."),
 
 				g.Label("\n\n\n"),
 				g.Align(g.AlignCenter).To(
-					g.Button("Compress").Size(125, 30).OnClick(func() {
+					g.Button("Create").Size(125, 30).OnClick(func() {
 						dependencyCheck()
 						if ffmpegNotFound || ffprobeNotFound {
 							return
@@ -421,7 +2309,7 @@ func loop() {
 							return
 						} else {
 							invalidFile = false
-							go beginAudioConvert()
+							go beginAssetConvert()
 						}
 					}),
 				),
@@ -468,36 +2356,6 @@ func loop() {
 					}),
 				),
 			),
-			/* // Gif converter GUI
-			g.TabItem("Gif Converter").Layout(
-				// File Selection
-				g.Label("Work in progress\n"),
-				g.Label("Video File"),
-				g.Row(
-					g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
-						g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
-							g.InputText(&filePath),
-						),
-					),
-					g.Button("Select...").OnClick(func() {
-						filename, err := dialog.File().Title("Select a File").Load()
-						if err != nil {
-							log.Println(err)
-						}
-						log.Println("Selected file:", filename)
-						filePath = strings.ReplaceAll(filename, `\`, "/")
-					}),
-				),
-
-				g.Button("Convert").OnClick(func() {
-					if encodingDone {
-						return
-					} else {
-						invalidFile = false
-						go beginGifConvert()
-					}
-				}),
-			), */
 		),
 	)
 }
@@ -533,6 +2391,13 @@ func dependencyCheck() {
 		}
 	}
 
+	// List what the build can encode now so the layout doesn't have to run ffmpeg,
+	// then recheck the codec of the preset applied at startup
+	if !ffmpegNotFound && !invalidFFmpeg {
+		cacheFFmpegCapabilities()
+		checkPresetCodec()
+	}
+
 	if !ffprobeNotFound {
 		cmd := exec.Command("./ffprobe.exe", "-version")
 		cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
@@ -552,9 +2417,89 @@ func dependencyCheck() {
 	}
 }
 
+// Explains what the audio allocator will pick for the selected file and target size
+func audioAllocationPreview() string {
+	targetBytes, err := parseTargetSize(strTargetSize, int(targetSizeUnit))
+	if err != nil || selectedDuration <= 0 {
+		return "Audio: picked from the target size when encoding starts"
+	}
+	platform := platformProfiles[selectedPlatform]
+	plan := planEncode(platform, targetBytes, codecH264, 0, 0, 0, selectedDuration)
+	total := calculateTarget(plan.TargetBytes, float32(plan.Duration), conservativeBitrate)
+	return allocateAudio(total, int(audioContent), int(videoAudioCodec)).String()
+}
+
+// Settings for the audio to video mode of the audio converter
+func audioVideoLayout() g.Widget {
+	imageLabel := "No image selected"
+	if audioVisualImage != "" {
+		imageLabel = filepath.Base(audioVisualImage)
+	}
+	return g.Layout{
+		g.Row(
+			g.Label("Target Size"),
+			g.Style().SetColor(g.StyleColorFrameBg, color.RGBA{0xF3, 0xF3, 0xF3, 255}).To(
+				g.Style().SetColor(g.StyleColorText, color.RGBA{0x00, 0x00, 0x00, 255}).To(
+					g.InputText(&strAudioTargetSize).Size(75),
+				),
+			),
+			g.Combo("##AudioVideoUnit", sizeUnitNames[audioTargetUnit], sizeUnitNames, &audioTargetUnit).Size(60),
+		),
+		g.Row(
+			g.Label("Visual"),
+			g.Combo("##AudioVisual", audioVisualNames[audioVisual], audioVisualNames, &audioVisual).Size(100),
+			g.Tooltip("Visual tip").Layout(
+				g.BulletText("Cover Art uses the picture embedded in the audio file, if there is one"),
+				g.BulletText("Image shows a picture you choose"),
+				g.BulletText("Waveform and Spectrum draw the audio, they use a little more of the target size"),
+			),
+			g.Condition(audioVisual == visualImage, g.Layout{
+				g.Button("Select Image...").OnClick(func() {
+					filename, err := dialog.File().Filter("Images", "png", "jpg", "jpeg", "webp", "bmp").Title("Select an Image").Load()
+					if err != nil {
+						log.Println(err)
+						return
+					}
+					audioVisualImage = strings.ReplaceAll(filename, `\`, "/")
+				}),
+				g.Label(imageLabel),
+			}, g.Layout{}),
+		),
+	}
+}
+
+// Size and byte limit of the selected asset type
+func assetSpecLabel() string {
+	spec := assetSpecs[selectedAsset]
+	return fmt.Sprintf("%dx%d, up to %s", spec.Width, spec.Height, formatBytes(spec.MaxBytes))
+}
+
+func addedAudioLabel() string {
+	if addedAudioPath == "" {
+		return "No file selected"
+	}
+	return filepath.Base(addedAudioPath)
+}
+
+// Byte count of the target size as typed, shown below the target size
+func targetSizeLabel() string {
+	targetBytes, err := parseTargetSize(strTargetSize, int(targetSizeUnit))
+	if err != nil {
+		return err.Error()
+	}
+	return formatBytes(targetBytes)
+}
+
+// Name shown in the preset combo box
+func presetPreview() string {
+	return allPresets()[selectedPreset].Name
+}
+
 func main() {
 	// Check if dependencies exist
 	go dependencyCheck()
+	loadUserPresets()
+	applyPreset(allPresets()[selectedPreset])
 
 	// Start giu
 	wnd := g.NewMasterWindow("Discord Media Tool", 400, 300, g.MasterWindowFlagsNotResizable)
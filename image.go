@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ffmpeg "github.com/Gordon-T/ffmpeg-go"
+)
+
+// Image output formats, indexes match imageFormatNames
+const (
+	imageJPEG = iota
+	imageWebP
+	imageAVIF
+)
+
+var imageFormatNames = []string{"JPEG", "WebP", "AVIF"}
+var imageEncoders = []string{"mjpeg", "libwebp", "libaom-av1"}
+var imageSuffixes = []string{"_jpeg.jpg", "_webp.webp", "_avif.avif"}
+
+// Longest side options, indexes match imageMaxSizeNames
+var imageMaxSizes = []int{0, 3840, 2560, 1920, 1280}
+var imageMaxSizeNames = []string{"Original", "3840", "2560", "1920", "1280"}
+
+// Settings for an image, filled in from the Image Converter tab
+type ImageOptions struct {
+	Format      int
+	MaxSize     int // longest side in pixels, 0 keeps the original
+	TargetBytes int64
+}
+
+// Returns the encoder arguments for a quality from 1 (smallest) to 100 (best)
+func imageQualityArgs(format int, quality int) ffmpeg.KwArgs {
+	switch format {
+	case imageWebP:
+		return ffmpeg.KwArgs{"quality": strconv.Itoa(quality), "compression_level": "6"}
+	case imageAVIF:
+		crf := int(math.Round(63 - float64(quality)*0.63))
+		return ffmpeg.KwArgs{"crf": strconv.Itoa(crf), "b:v": "0", "still-picture": "1", "cpu-used": "4"}
+	default:
+		// mjpeg's qscale goes from 2 (best) to 31 (smallest)
+		q := int(math.Round(31 - float64(quality)/100*29))
+		return ffmpeg.KwArgs{"q:v": strconv.Itoa(q)}
+	}
+}
+
+// Reads the EXIF orientation of a JPEG, 1 if there is none. ffmpeg doesn't rotate
+// JPEGs by their EXIF orientation on every version so it's applied as a filter instead
+func exifOrientation(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	// Walk the JPEG segments looking for the APP1 Exif segment
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 {
+			break
+		}
+		segment := data[i+4 : min(i+2+length, len(data))]
+		if marker == 0xE1 && len(segment) > 14 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		if marker == 0xDA { // image data starts, no more metadata
+			break
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// Finds the orientation tag (0x0112) in the first IFD of EXIF's TIFF structure
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if string(tiff[:2]) == "II" {
+		order = binary.LittleEndian
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 0 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < entries; e++ {
+		entry := ifd + 2 + e*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+		}
+	}
+	return 1
+}
+
+// Filters that turn the pixels the way the EXIF orientation says, since the EXIF is stripped
+var orientationFilters = map[int]string{
+	2: "hflip",
+	3: "hflip,vflip",
+	4: "vflip",
+	5: "transpose=0",
+	6: "transpose=1",
+	7: "transpose=3",
+	8: "transpose=2",
+}
+
+// Fits the longest side within maxSize while keeping the aspect ratio
+func limitLongSide(width int, height int, maxSize int) (int, int) {
+	longSide := max(width, height)
+	if maxSize <= 0 || longSide <= maxSize {
+		return width, height
+	}
+	scale := float64(maxSize) / float64(longSide)
+	return int(math.Round(float64(width) * scale)), int(math.Round(float64(height) * scale))
+}
+
+// Returns the ffmpeg arguments for one encode of the image at the given quality
+func imageArgs(opts ImageOptions, filters []string, quality int) ffmpeg.KwArgs {
+	ffmpegArguments := ffmpeg.KwArgs{
+		"c:v":          imageEncoders[opts.Format],
+		"frames:v":     "1",
+		"map_metadata": "-1", // drops EXIF, including GPS
+	}
+	if len(filters) > 0 {
+		ffmpegArguments["vf"] = strings.Join(filters, ",")
+	}
+	addArgs(ffmpegArguments, imageQualityArgs(opts.Format, quality))
+	return ffmpegArguments
+}
+
+// Compresses the image, searching for the best quality that fits the target size.
+// Returns the output path, or "" if encoding failed, and a note on the quality it picked
+func imageEncode(filePath string, opts ImageOptions, width int, height int) (string, string) {
+	var fileName string = filepath.Base(filePath)
+	var outputName = filepath.Dir(filePath) + `\` + strings.TrimSuffix(fileName, filepath.Ext(fileName)) + imageSuffixes[opts.Format]
+
+	// Turn the image the right way up, then downscale it
+	var filters []string
+	orientation := exifOrientation(filePath)
+	if filter, ok := orientationFilters[orientation]; ok {
+		filters = append(filters, filter)
+		if orientation >= 5 {
+			width, height = height, width
+		}
+	}
+	if w, h := limitLongSide(width, height, opts.MaxSize); w != width || h != height {
+		filters = append(filters, fmt.Sprintf("scale=%d:%d:flags=lanczos", w, h))
+	}
+
+	encode := func(quality int) (int64, bool) {
+		imageErr := ffmpeg.Input(filePath, ffmpeg.KwArgs{"noautorotate": ""}).Output(outputName, imageArgs(opts, filters, quality)).OverWriteOutput().SetFfmpegPath("./ffmpeg.exe").ErrorToStdOut().Run()
+		if imageErr != nil {
+			encodeError = true
+			log.Printf("Error occurred while encoding image: %v", imageErr)
+			return 0, false
+		}
+		info, err := os.Stat(outputName)
+		if err != nil {
+			log.Println("Error checking image size:", err)
+			return 0, false
+		}
+		log.Printf("image quality %d: %v bytes", quality, info.Size())
+		return info.Size(), true
+	}
+
+	// Binary search for the highest quality under the target
+	low, high, best, last, tries := 1, 100, 0, 0, 0
+	for low <= high {
+		quality := (low + high) / 2
+		tries++
+		attemptStatus = fmt.Sprintf("Try %d, quality %d", tries, quality)
+		size, ok := encode(quality)
+		if !ok {
+			return "", ""
+		}
+		last = quality
+		if size <= opts.TargetBytes {
+			best = quality
+			low = quality + 1
+		} else {
+			high = quality - 1
+		}
+	}
+	if best == 0 {
+		return outputName, "Still too big at the lowest quality, try a smaller max size"
+	}
+	// The last try may have been over the target, encode the best fit again
+	if last != best {
+		attemptStatus = fmt.Sprintf("Saving at quality %d", best)
+		if _, ok := encode(best); !ok {
+			return "", ""
+		}
+	}
+	return outputName, fmt.Sprintf("Fit at quality %d of 100 after %d tries", best, tries)
+}
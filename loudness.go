@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	ffmpeg "github.com/Gordon-T/ffmpeg-go"
+)
+
+// Loudness targets in LUFS, indexes match loudnessTargetNames
+var loudnessTargets = []float64{-14, -16, -23}
+var loudnessTargetNames = []string{"-14 LUFS (Streaming)", "-16 LUFS (Podcast)", "-23 LUFS (EBU R128)"}
+
+const (
+	loudnessTruePeak = -1.5 // dBTP
+	loudnessRange    = 11   // LU
+)
+
+// Values from the loudnorm measuring pass, loudnorm prints them all as strings
+type LoudnessMeasurement struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// Measured loudness of the source and the target it was normalized to
+type LoudnessResult struct {
+	Target     float64 `json:"target_lufs"`
+	Integrated float64 `json:"measured_i"`
+	TruePeak   float64 `json:"measured_tp"`
+	Range      float64 `json:"measured_lra"`
+}
+
+func (l LoudnessResult) String() string {
+	return fmt.Sprintf("Loudness: %.1f LUFS, %.1f dBTP peak, %.1f LU range, normalized to %v LUFS", l.Integrated, l.TruePeak, l.Range, l.Target)
+}
+
+// Runs the first loudnorm pass over the chosen audio tracks of the file. duration cuts the
+// measurement short for trimmed outputs, 0 measures the whole file
+func measureLoudness(filePath string, target float64, duration float64, tracks []AudioTrack) (LoudnessMeasurement, error) {
+	var m LoudnessMeasurement
+	args := []string{"-hide_banner", "-i", filePath}
+	if duration > 0 {
+		args = append(args, "-t", strconv.FormatFloat(duration, 'f', 3, 64))
+	}
+	filter := fmt.Sprintf("loudnorm=I=%v:TP=%v:LRA=%v:print_format=json", target, loudnessTruePeak, loudnessRange)
+	args = append(args, "-vn")
+	args = append(args, ffmpeg.ConvertKwargsToCmdLineArgs(audioTrackArgs(tracks, []string{filter}))...)
+	args = append(args, "-f", "null", "-")
+	output, err := runFFmpegLog(args...)
+	if err != nil {
+		return m, err
+	}
+
+	// The stats are the last JSON object in the log
+	start := strings.LastIndex(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return m, errors.New("loudnorm didn't print any measurements")
+	}
+	err = json.Unmarshal([]byte(output[start:end+1]), &m)
+	if err != nil {
+		return m, err
+	}
+	// Silent files measure as -inf and can't be normalized
+	if _, err := strconv.ParseFloat(m.InputI, 64); err != nil {
+		return m, errors.New("audio is silent, nothing to normalize")
+	}
+	return m, nil
+}
+
+// Builds the second pass filter that applies linear normalization with the measured values.
+// loudnorm upsamples to 192 kHz internally so the audio is resampled back afterwards
+func loudnormFilter(target float64, m LoudnessMeasurement) string {
+	return fmt.Sprintf("loudnorm=I=%v:TP=%v:LRA=%v:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true,aresample=48000",
+		target, loudnessTruePeak, loudnessRange, m.InputI, m.InputTP, m.InputLRA, m.InputThresh, m.TargetOffset)
+}
+
+func (m LoudnessMeasurement) result(target float64) *LoudnessResult {
+	l := &LoudnessResult{Target: target}
+	l.Integrated, _ = strconv.ParseFloat(m.InputI, 64)
+	l.TruePeak, _ = strconv.ParseFloat(m.InputTP, 64)
+	l.Range, _ = strconv.ParseFloat(m.InputLRA, 64)
+	return l
+}
+
+// Measures the file and returns the normalization filter and result to show.
+// Returns "" and a warning if the file couldn't be measured so the encode can carry on without it
+func planLoudness(filePath string, target float64, duration float64, tracks []AudioTrack) (string, *LoudnessResult, string) {
+	loudnessNow = true
+	defer func() { loudnessNow = false }()
+	m, err := measureLoudness(filePath, target, duration, tracks)
+	if err != nil {
+		log.Println("Error measuring loudness: ", err)
+		return "", nil, "Loudness wasn't normalized: " + err.Error()
+	}
+	log.Printf("loudness: %+v", m)
+	return loudnormFilter(target, m), m.result(target), ""
+}
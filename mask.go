@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Ways of hiding a region, indexes match maskModeNames
+const (
+	maskBlur = iota
+	maskPixelate
+	maskBlack
+)
+
+var maskModeNames = []string{"Blur", "Pixelate", "Black Box"}
+
+// Part of the frame to hide, like usernames or chat in a game's HUD. Sizes are in percent of the
+// frame so a preset made on one clip fits every clip of the same game at any resolution
+type MaskRegion struct {
+	Mode   int32   `json:"mode"` // index into maskModeNames
+	X      float32 `json:"x"`
+	Y      float32 `json:"y"`
+	Width  float32 `json:"width"`
+	Height float32 `json:"height"`
+	Start  float32 `json:"start,omitempty"` // seconds of the source
+	End    float32 `json:"end,omitempty"`   // 0 hides it until the end
+}
+
+func (r MaskRegion) validate() error {
+	if r.Mode < 0 || int(r.Mode) >= len(maskModeNames) {
+		return fmt.Errorf("unknown region mode %d", r.Mode)
+	}
+	if r.X < 0 || r.Y < 0 || r.Width < 1 || r.Height < 1 || r.X+r.Width > 100.01 || r.Y+r.Height > 100.01 {
+		return errors.New("hidden regions must be inside the frame and at least 1% wide and high")
+	}
+	if r.Start < 0 || (r.End > 0 && r.End <= r.Start) {
+		return errors.New("a hidden region must end after it starts")
+	}
+	return nil
+}
+
+func validateMasks(regions []MaskRegion) error {
+	for _, r := range regions {
+		if err := r.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the region in pixels of a frame of the given size, rounded to even numbers for yuv420p
+func (r MaskRegion) rect(width int, height int) CropRect {
+	x := evenSize(float64(r.X) / 100 * float64(width))
+	y := evenSize(float64(r.Y) / 100 * float64(height))
+	return CropRect{
+		Width:  max(min(evenSize(float64(r.Width)/100*float64(width)), width-x), 2),
+		Height: max(min(evenSize(float64(r.Height)/100*float64(height)), height-y), 2),
+		X:      x,
+		Y:      y,
+	}
+}
+
+// Returns the timeline option that limits the filter to the region's time range, or ""
+func (r MaskRegion) enable() string {
+	switch {
+	case r.End > 0:
+		return fmt.Sprintf(`:enable=between(t\,%.3f\,%.3f)`, r.Start, r.End)
+	case r.Start > 0:
+		return fmt.Sprintf(`:enable=gte(t\,%.3f)`, r.Start)
+	}
+	return ""
+}
+
+// Returns the filters that hide the regions on a frame of the given size. Blurred and pixelated
+// regions are cut out, changed and laid back over the frame, black boxes are drawn straight on it
+func maskFilters(regions []MaskRegion, width int, height int) []string {
+	var filters []string
+	for i, r := range regions {
+		rect := r.rect(width, height)
+		if r.Mode == maskBlack {
+			filters = append(filters, fmt.Sprintf("drawbox=x=%d:y=%d:w=%d:h=%d:color=black:t=fill%s", rect.X, rect.Y, rect.Width, rect.Height, r.enable()))
+			continue
+		}
+		effect := "boxblur=luma_radius=min(w\\,h)/4:luma_power=3:chroma_radius=min(cw\\,ch)/4:chroma_power=3"
+		if r.Mode == maskPixelate {
+			// Blocks scale with the frame so the text is just as unreadable at any resolution
+			block := min(max(height/45, 4), rect.Width, rect.Height)
+			effect = fmt.Sprintf("scale=%d:%d:flags=area,scale=%d:%d:flags=neighbor", max(rect.Width/block, 1), max(rect.Height/block, 1), rect.Width, rect.Height)
+		}
+		filters = append(filters, fmt.Sprintf("split[mb%d][mr%d];[mr%d]crop=%s,%s[mx%d];[mb%d][mx%d]overlay=%d:%d%s",
+			i, i, i, rect, effect, i, i, i, rect.X, rect.Y, r.enable()))
+	}
+	return filters
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	ffmpeg "github.com/Gordon-T/ffmpeg-go"
+)
+
+// What happens to the tags, cover art and location of the source, indexes match metadataPolicyNames
+const (
+	metadataKeepAll = iota
+	metadataStripPrivate
+	metadataStripAll
+)
+
+var metadataPolicyNames = []string{"Keep All", "Remove Location/Device", "Remove All"}
+
+// Parts of tag names phones and cameras use for where and on what a file was recorded
+var privateTagParts = []string{"location", "gps", "xyz", "make", "model", "com.android", "com.apple.quicktime"}
+
+func isPrivateTag(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range privateTagParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the ffmpeg arguments for the metadata policy. Removing a tag is done by setting
+// it to nothing since ffmpeg copies the source's global tags by default
+func metadataArgs(policy int, tags map[string]string) ffmpeg.KwArgs {
+	switch policy {
+	case metadataStripAll:
+		return ffmpeg.KwArgs{"map_metadata": "-1", "map_chapters": "-1"}
+	case metadataStripPrivate:
+		var cleared []string
+		for name := range tags {
+			if isPrivateTag(name) {
+				cleared = append(cleared, name+"=")
+			}
+		}
+		sort.Strings(cleared)
+		args := ffmpeg.KwArgs{"map_metadata": "0"}
+		if len(cleared) > 0 {
+			args["metadata"] = cleared
+		}
+		return args
+	default:
+		return ffmpeg.KwArgs{"map_metadata": "0"}
+	}
+}
+
+// Ogg keeps tags on the audio stream instead of the file, so the source's file tags are copied there.
+// The copy brings the location and device tags along, so they're cleared on the stream as well
+func oggMetadataArgs(policy int, tags map[string]string) ffmpeg.KwArgs {
+	args := metadataArgs(policy, tags)
+	if policy != metadataStripAll {
+		args["map_metadata:s:a"] = "0:g"
+		if cleared, ok := args["metadata"]; ok {
+			args["metadata:s:a"] = cleared
+		}
+	}
+	return args
+}
+
+// Checks if the output container can hold cover art
+func keepsCoverArt(policy int, suffix string) bool {
+	if policy == metadataStripAll {
+		return false
+	}
+	return strings.HasSuffix(suffix, ".mp3") || strings.HasSuffix(suffix, ".m4a") || strings.HasSuffix(suffix, ".flac")
+}
+
+// Maps the cover art next to the audio so it's copied into the output unchanged
+func applyCoverArt(ffmpegArguments ffmpeg.KwArgs, coverArt int) {
+	audioMap, ok := ffmpegArguments["map"].(string)
+	if !ok {
+		audioMap = "0:a:0"
+	}
+	delete(ffmpegArguments, "vn")
+	ffmpegArguments["map"] = []string{audioMap, fmt.Sprintf("0:v:%d", coverArt)}
+	ffmpegArguments["c:v"] = "copy"
+	ffmpegArguments["disposition:v"] = "attached_pic"
+}
+
+// Lines describing the metadata of the file, shown in the metadata view
+var metadataLines []string
+
+// Probes the file and lists its tags, cover art and which tags would be removed for privacy
+func loadMetadataView(fileName string) {
+	metadataLines = []string{"Reading metadata..."}
+	info, err := ffmpeg.Probe(fileName)
+	if err != nil {
+		log.Println("Error reading metadata:", err)
+		metadataLines = []string{"Couldn't read the file"}
+		return
+	}
+	var mediaInfo MediaInfo
+	err = json.Unmarshal([]byte(info), &mediaInfo)
+	if err != nil {
+		log.Println("Error parsing json data from ffprobe:", err)
+		metadataLines = []string{"Couldn't read the file"}
+		return
+	}
+
+	var lines []string
+	var names []string
+	for name := range mediaInfo.Format.Tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		line := name + ": " + mediaInfo.Format.Tags[name]
+		if isPrivateTag(name) {
+			line += "  (location/device)"
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No tags")
+	}
+	if mediaInfo.coverArt() >= 0 {
+		lines = append(lines, "Has cover art")
+	}
+	metadataLines = lines
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOggMetadataArgsStripPrivate(t *testing.T) {
+	tags := map[string]string{
+		"title":                    "Clip",
+		"location":                 "+51.5007-000.1246/",
+		"com.apple.quicktime.make": "Apple",
+	}
+	want := []string{"com.apple.quicktime.make=", "location="}
+
+	args := oggMetadataArgs(metadataStripPrivate, tags)
+	if got := args["map_metadata:s:a"]; got != "0:g" {
+		t.Errorf("map_metadata:s:a = %v, want 0:g", got)
+	}
+	for _, key := range []string{"metadata", "metadata:s:a"} {
+		if got := args[key]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+
+	args = oggMetadataArgs(metadataKeepAll, tags)
+	if _, ok := args["metadata:s:a"]; ok {
+		t.Errorf("Keep All cleared stream tags: %v", args["metadata:s:a"])
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// Upload limits of a site the output is meant for. Zero values mean there is no limit
+type PlatformProfile struct {
+	Name         string
+	MaxSizeBytes int64
+	MaxDuration  float64 // seconds
+	MaxWidth     int     // landscape box, rotated for portrait videos
+	MaxHeight    int
+	Codecs       []int
+	Containers   []string
+}
+
+var platformProfiles = []PlatformProfile{
+	{Name: "Discord", MaxSizeBytes: 500 * megabyte, Codecs: []int{codecH264, codecVP9, codecHEVC, codecAV1}, Containers: []string{"mp4", "webm"}},
+	{Name: "Telegram", MaxSizeBytes: 2000 * megabyte, Codecs: []int{codecH264, codecHEVC}, Containers: []string{"mp4"}},
+	{Name: "WhatsApp", MaxSizeBytes: 16 * megabyte, MaxWidth: 1280, MaxHeight: 720, Codecs: []int{codecH264}, Containers: []string{"mp4"}},
+	{Name: "Slack", MaxSizeBytes: 1000 * megabyte, Codecs: []int{codecH264, codecVP9}, Containers: []string{"mp4", "webm"}},
+	{Name: "Email (25 MB)", MaxSizeBytes: 25 * megabyte, Codecs: []int{codecH264}, Containers: []string{"mp4"}},
+	{Name: "X/Twitter", MaxSizeBytes: 512 * megabyte, MaxDuration: 140, MaxWidth: 1920, MaxHeight: 1200, Codecs: []int{codecH264}, Containers: []string{"mp4"}},
+}
+
+var platformNames = func() []string {
+	var names []string
+	for _, p := range platformProfiles {
+		names = append(names, p.Name)
+	}
+	return names
+}()
+
+// Checks both the codec and the container it gets written to
+func (p PlatformProfile) allowsCodec(codec int) bool {
+	_, suffix := videoCodecArgs(codec, "0", 0)
+	container := strings.TrimPrefix(filepath.Ext(suffix), ".")
+	codecOK := false
+	for _, c := range p.Codecs {
+		if c == codec {
+			codecOK = true
+		}
+	}
+	for _, c := range p.Containers {
+		if c == container {
+			return codecOK
+		}
+	}
+	return false
+}
+
+// The settings for one encode after the platform limits have been applied
+type EncodePlan struct {
+	TargetBytes int64
+	Duration    float64 // seconds of output, shorter than the source when trimmed
+	Trimmed     bool
+	Width       int
+	Height      int
+	Codec       int
+	PadHeight   int // added above the video by a caption bar, not part of Height
+	Warnings    []string
+}
+
+// Fits the requested settings within the platform limits, noting every change as a warning
+func planEncode(p PlatformProfile, targetBytes int64, codec int, width int, height int, maxSide int, duration float64) EncodePlan {
+	plan := EncodePlan{TargetBytes: targetBytes, Duration: duration, Codec: codec}
+
+	if p.MaxSizeBytes > 0 && targetBytes > p.MaxSizeBytes {
+		plan.TargetBytes = p.MaxSizeBytes
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("Target size lowered to the %s limit of %s", p.Name, formatBytes(p.MaxSizeBytes)))
+	}
+
+	if p.MaxDuration > 0 && duration > p.MaxDuration {
+		plan.Duration = p.MaxDuration
+		plan.Trimmed = true
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("Trimmed to the first %v seconds, %s allows up to %v seconds", p.MaxDuration, p.Name, p.MaxDuration))
+	}
+
+	plan.Width, plan.Height = limitShortSide(width, height, maxSide)
+	boxWidth, boxHeight := fitInBox(plan.Width, plan.Height, p.MaxWidth, p.MaxHeight)
+	if boxWidth != plan.Width || boxHeight != plan.Height {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("Downscaled to %dx%d to fit the %s limit of %dx%d", boxWidth, boxHeight, p.Name, p.MaxWidth, p.MaxHeight))
+		plan.Width, plan.Height = boxWidth, boxHeight
+	}
+
+	if codec != codecAuto && !p.allowsCodec(codec) {
+		plan.Codec = p.Codecs[0]
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s isn't supported by %s, using %s instead", videoCodecNames[codec], p.Name, videoCodecNames[plan.Codec]))
+	}
+	return plan
+}
+
+// Returns the scale filter needed to get from the source size to the planned size, or "" if none is needed
+func (plan EncodePlan) scaleFilter(width int, height int) string {
+	if plan.Width == width && plan.Height == height {
+		return ""
+	}
+	return fmt.Sprintf("scale=%d:%d", plan.Width, plan.Height)
+}
+
+// Fits the shorter side of the video within maxSide while keeping the aspect ratio
+func limitShortSide(width int, height int, maxSide int) (int, int) {
+	shortSide := min(width, height)
+	if maxSide <= 0 || shortSide <= maxSide {
+		return width, height
+	}
+	scale := float64(maxSide) / float64(shortSide)
+	return evenSize(float64(width) * scale), evenSize(float64(height) * scale)
+}
+
+// Fits the video within a landscape box, the box is rotated for portrait videos
+func fitInBox(width int, height int, boxWidth int, boxHeight int) (int, int) {
+	if boxWidth <= 0 || boxHeight <= 0 || width == 0 || height == 0 {
+		return width, height
+	}
+	if height > width {
+		boxWidth, boxHeight = boxHeight, boxWidth
+	}
+	scale := math.Min(float64(boxWidth)/float64(width), float64(boxHeight)/float64(height))
+	if scale >= 1 {
+		return width, height
+	}
+	return evenSize(float64(width) * scale), evenSize(float64(height) * scale)
+}
+
+// Rounds down to an even number since yuv420p needs even dimensions
+func evenSize(v float64) int {
+	return int(v/2) * 2
+}
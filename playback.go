@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Playback effects, indexes match playbackEffectNames
+const (
+	effectNone = iota
+	effectReverse
+	effectBoomerang // plays forward then backward
+)
+
+var playbackEffectNames = []string{"None", "Reverse", "Boomerang"}
+
+// Speed choices, indexes match playbackSpeedNames
+var playbackSpeeds = []float64{0.25, 0.5, 0.75, 1, 1.25, 1.5, 2, 3, 4}
+var playbackSpeedNames = []string{"0.25x", "0.5x", "0.75x", "1x", "1.25x", "1.5x", "2x", "3x", "4x"}
+
+const normalSpeed = 3 // index of 1x
+
+// Reversing keeps every frame in memory, so it's only allowed on short clips
+const maxReverseSeconds = 60
+
+type PlaybackOptions struct {
+	Speed     float64
+	Effect    int
+	FrameRate float64 // of the source, sped up video is brought back to it. 0 keeps every frame
+}
+
+func (p PlaybackOptions) changed() bool {
+	return p.Speed != 1 || p.Effect != effectNone
+}
+
+// Returns how long the output is for the given seconds of source
+func (p PlaybackOptions) outputDuration(source float64) float64 {
+	duration := source / p.Speed
+	if p.Effect == effectBoomerang {
+		duration *= 2
+	}
+	return duration
+}
+
+// Returns how many seconds of source make up the given seconds of output
+func (p PlaybackOptions) sourceDuration(output float64) float64 {
+	duration := output * p.Speed
+	if p.Effect == effectBoomerang {
+		duration /= 2
+	}
+	return duration
+}
+
+// Checks the clip is short enough to reverse
+func (p PlaybackOptions) check(source float64) error {
+	if p.Effect != effectNone && source > maxReverseSeconds {
+		return fmt.Errorf("%s only works on clips up to %d seconds, this one is %.0f", playbackEffectNames[p.Effect], maxReverseSeconds, source)
+	}
+	return nil
+}
+
+// Returns the video filters for the speed and effect. length cuts the source to that many seconds
+// first so reverse doesn't have to hold the rest of the video, 0 uses all of it
+func (p PlaybackOptions) videoFilters(length float64) []string {
+	var filters []string
+	if p.Effect != effectNone && length > 0 {
+		filters = append(filters, fmt.Sprintf("trim=duration=%.3f", length), "setpts=PTS-STARTPTS")
+	}
+	switch p.Effect {
+	case effectReverse:
+		filters = append(filters, "reverse")
+	case effectBoomerang:
+		filters = append(filters, "split[fwd][back];[back]reverse[rev];[fwd][rev]concat=n=2:v=1:a=0")
+	}
+	if p.Speed != 1 {
+		filters = append(filters, "setpts=PTS/"+strconv.FormatFloat(p.Speed, 'f', -1, 64))
+		// Speeding up squeezes more frames into each second, drop back to the source frame rate
+		if p.Speed > 1 && p.FrameRate > 0 {
+			filters = append(filters, "fps="+strconv.FormatFloat(p.FrameRate, 'f', 3, 64))
+		}
+	}
+	return filters
+}
+
+// Returns the audio filters for the speed and effect. atempo keeps the pitch, and each atempo
+// is kept between 0.5 and 2 so the speed is split over several when needed.
+// Boomerangs have no audio so they get none
+func (p PlaybackOptions) audioFilters() []string {
+	var filters []string
+	if p.Effect == effectReverse {
+		filters = append(filters, "areverse")
+	}
+	speed := p.Speed
+	for speed > 2 {
+		filters = append(filters, "atempo=2")
+		speed /= 2
+	}
+	for speed < 0.5 {
+		filters = append(filters, "atempo=0.5")
+		speed /= 0.5
+	}
+	if speed != 1 {
+		filters = append(filters, "atempo="+strconv.FormatFloat(speed, 'f', -1, 64))
+	}
+	return filters
+}
+
+// Parses ffprobe's frame rate fraction like 30000/1001
+func parseFrameRate(rate string) float64 {
+	num, den, found := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !found {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+)
+
+// File next to DMT.exe where user created presets are kept
+const userPresetsFile = "./presets.json"
+
+// A named bundle of video converter settings
+type Preset struct {
+	Name          string  `json:"name"`
+	TargetSize    float64 `json:"target_size"`
+	SizeUnit      string  `json:"size_unit"`             // "MB", "MiB", "KB", "KiB" or "bytes"
+	Codec         string  `json:"codec"`                 // "H264", "VP9", "HEVC", "AV1" or "Auto"
+	AudioBitrate  int     `json:"audio_bitrate"`         // kb/s
+	AudioCodec    string  `json:"audio_codec,omitempty"` // "Opus", "AAC" or "No Audio", Opus if empty
+	AudioMono     bool    `json:"audio_mono,omitempty"`
+	MaxResolution int     `json:"max_resolution"` // shorter side in pixels, 0 keeps the original
+
+	Masks []MaskRegion `json:"masks,omitempty"` // regions hidden on every clip, like a game's chat box
+}
+
+var builtInPresets = []Preset{
+	{Name: "Discord Free (10 MB)", TargetSize: 10, SizeUnit: "MB", Codec: "H264", AudioBitrate: 96, MaxResolution: 720},
+	{Name: "Discord Nitro Basic (50 MB)", TargetSize: 50, SizeUnit: "MB", Codec: "H264", AudioBitrate: 128, MaxResolution: 1080},
+	{Name: "Discord Nitro (500 MB)", TargetSize: 500, SizeUnit: "MB", Codec: "H264", AudioBitrate: 160, MaxResolution: 0},
+	{Name: "Server Boost Level 3 (100 MB)", TargetSize: 100, SizeUnit: "MB", Codec: "H264", AudioBitrate: 128, MaxResolution: 1080},
+}
+
+// Max resolution options, indexes match resolutionNames
+var resolutionSizes = []int{0, 1080, 720, 480, 360}
+var resolutionNames = []string{"Original", "1080p", "720p", "480p", "360p"}
+
+var userPresets []Preset
+
+// Built in presets followed by the user's own presets
+func allPresets() []Preset {
+	return append(append([]Preset{}, builtInPresets...), userPresets...)
+}
+
+func presetNames() []string {
+	var names []string
+	for _, p := range allPresets() {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// Checks that a preset has usable values, used for both the GUI and imported files
+func (p Preset) validate() error {
+	if p.Name == "" {
+		return errors.New("preset has no name")
+	}
+	if _, err := parseTargetSize(strconv.FormatFloat(p.TargetSize, 'f', -1, 64), sizeUnitIndex(p.SizeUnit)); err != nil {
+		return err
+	}
+	if codecIndex(p.Codec) < 0 {
+		return errors.New("unknown codec " + p.Codec)
+	}
+	if videoAudioCodecIndex(p.AudioCodec) < 0 {
+		return errors.New("unknown audio codec " + p.AudioCodec)
+	}
+	if p.AudioBitrate <= 0 || p.AudioBitrate > 512 {
+		return errors.New("audio bitrate must be between 1 and 512 Kb/s")
+	}
+	if resolutionIndex(p.MaxResolution) < 0 {
+		return errors.New("unsupported max resolution " + strconv.Itoa(p.MaxResolution))
+	}
+	return validateMasks(p.Masks)
+}
+
+// Returns the codec radio button index for a codec name, or -1 if unknown
+func codecIndex(name string) int {
+	if name == "Auto" {
+		return codecAuto
+	}
+	for i, n := range videoCodecNames {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns the index in videoAudioCodecNames, older presets without an audio codec use Opus
+func videoAudioCodecIndex(name string) int {
+	if name == "" {
+		return videoAudioOpus
+	}
+	for i, n := range videoAudioCodecNames {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func codecName(index int) string {
+	if index == codecAuto {
+		return "Auto"
+	}
+	return videoCodecNames[index]
+}
+
+func resolutionIndex(maxResolution int) int {
+	for i, r := range resolutionSizes {
+		if r == maxResolution {
+			return i
+		}
+	}
+	return -1
+}
+
+// Selects the preset with the given name in the preset combo box
+func selectPreset(name string) {
+	for i, n := range presetNames() {
+		if n == name {
+			selectedPreset = int32(i)
+		}
+	}
+}
+
+// Falls back to H264 when the FFmpeg build can't encode the selected codec. H264 is always needed,
+// the other codecs depend on how FFmpeg was built. Does nothing until dependencyCheck has probed the build
+func checkPresetCodec() {
+	if !ffmpegProbed || videoCompression == codecAuto || videoCompression == codecH264 || videoCodecAvailable[videoCompression] {
+		return
+	}
+	settingsError = "Your FFmpeg build can't encode " + videoCodecNames[videoCompression] + ", using H264 instead"
+	videoCompression = codecH264
+}
+
+// Copies the preset into the video converter settings
+func applyPreset(p Preset) {
+	strTargetSize = strconv.FormatFloat(p.TargetSize, 'f', -1, 64)
+	targetSizeUnit = int32(sizeUnitIndex(p.SizeUnit))
+	videoCompression = codecIndex(p.Codec)
+	checkPresetCodec()
+	strVideoAudioBitrate = strconv.Itoa(p.AudioBitrate)
+	videoAudioCodec = int32(videoAudioCodecIndex(p.AudioCodec))
+	videoAudioMono = p.AudioMono
+	maxResolution = int32(resolutionIndex(p.MaxResolution))
+	maskRegions = append([]MaskRegion{}, p.Masks...)
+	strPresetName = p.Name
+}
+
+// Builds a preset from the current video converter settings
+func presetFromSettings(name string) (Preset, error) {
+	size, err := strconv.ParseFloat(strTargetSize, 64)
+	if err != nil {
+		return Preset{}, errors.New("target size must be a number")
+	}
+	audioBitrate, err := strconv.Atoi(strVideoAudioBitrate)
+	if err != nil {
+		return Preset{}, errors.New("audio bitrate must be a whole number")
+	}
+	p := Preset{
+		Name:          name,
+		TargetSize:    size,
+		SizeUnit:      sizeUnitNames[targetSizeUnit],
+		Codec:         codecName(videoCompression),
+		AudioBitrate:  audioBitrate,
+		AudioCodec:    videoAudioCodecNames[videoAudioCodec],
+		AudioMono:     videoAudioMono,
+		MaxResolution: resolutionSizes[maxResolution],
+		Masks:         append([]MaskRegion{}, maskRegions...),
+	}
+	return p, p.validate()
+}
+
+// Adds or replaces a user preset with the same name. Built in presets can't be overwritten
+func storeUserPreset(p Preset) error {
+	for _, b := range builtInPresets {
+		if b.Name == p.Name {
+			return errors.New("can't overwrite built in preset " + p.Name)
+		}
+	}
+	for i := range userPresets {
+		if userPresets[i].Name == p.Name {
+			userPresets[i] = p
+			return saveUserPresets()
+		}
+	}
+	userPresets = append(userPresets, p)
+	clampSelectedPreset()
+	return saveUserPresets()
+}
+
+func deleteUserPreset(name string) error {
+	for i := range userPresets {
+		if userPresets[i].Name == name {
+			userPresets = append(userPresets[:i], userPresets[i+1:]...)
+			clampSelectedPreset()
+			return saveUserPresets()
+		}
+	}
+	return errors.New("only user presets can be deleted")
+}
+
+func loadUserPresets() {
+	data, err := os.ReadFile(userPresetsFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Println("Error reading presets:", err)
+		}
+		return
+	}
+	var presets []Preset
+	err = json.Unmarshal(data, &presets)
+	if err != nil {
+		log.Println("Error parsing presets:", err)
+		return
+	}
+	for _, p := range presets {
+		if err := p.validate(); err != nil {
+			log.Printf("Skipping preset %q: %v", p.Name, err)
+			continue
+		}
+		userPresets = append(userPresets, p)
+	}
+	clampSelectedPreset()
+}
+
+// Keeps the selected preset in range after the preset list changes
+func clampSelectedPreset() {
+	if int(selectedPreset) >= len(allPresets()) {
+		selectedPreset = 0
+	}
+}
+
+func saveUserPresets() error {
+	data, err := json.MarshalIndent(userPresets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(userPresetsFile, data, 0644)
+}
+
+// Writes a single preset to a file so it can be shared
+func exportPreset(p Preset, path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Reads a preset file written by exportPreset and stores it as a user preset
+func importPreset(path string) (Preset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Preset{}, err
+	}
+	var p Preset
+	err = json.Unmarshal(data, &p)
+	if err != nil {
+		return Preset{}, errors.New("not a valid preset file")
+	}
+	if err := p.validate(); err != nil {
+		return Preset{}, err
+	}
+	return p, storeUserPreset(p)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Quality metric options, indexes match qualityMetricNames
+const (
+	metricVMAF = iota
+	metricSSIM
+	metricPSNR
+)
+
+var qualityMetricNames = []string{"VMAF", "SSIM", "PSNR"}
+
+type QualityScore struct {
+	Metric string  `json:"metric"`
+	Score  float64 `json:"score"`
+	Note   string  `json:"note,omitempty"`
+}
+
+var (
+	vmafScoreRe = regexp.MustCompile(`VMAF score: ([\d.]+)`)
+	ssimScoreRe = regexp.MustCompile(`SSIM .*All:([\d.]+)`)
+	psnrScoreRe = regexp.MustCompile(`PSNR .*average:([\d.]+|inf)`)
+)
+
+// Compares the encoded output against the source file with the chosen metric.
+// The output is scaled to the source resolution first since the metrics need matching frames.
+// The source is cut to duration so trimmed outputs are compared against the same part.
+// refFilters crop and rotate the source the same way as the output
+func scoreQuality(outputPath string, sourcePath string, metric int, width int, height int, duration float64, refFilters []string) *QualityScore {
+	strDuration := strconv.FormatFloat(duration, 'f', 3, 64)
+	return measureQuality([]string{"-i", outputPath, "-t", strDuration, "-i", sourcePath}, metric, width, height, duration, refFilters)
+}
+
+// Runs a quality metric where inputs holds the ffmpeg input arguments for the
+// distorted file followed by the reference file
+func measureQuality(inputs []string, metric int, width int, height int, duration float64, refFilters []string) *QualityScore {
+	score := &QualityScore{Metric: qualityMetricNames[metric]}
+
+	// Not every ffmpeg build ships with libvmaf, fall back to SSIM instead of failing
+	if metric == metricVMAF && !ffmpegHasFilter("libvmaf") {
+		log.Println("ffmpeg was built without libvmaf, scoring with SSIM instead")
+		metric = metricSSIM
+		score.Metric = qualityMetricNames[metric]
+		score.Note = "libvmaf unavailable"
+	}
+
+	var filter string
+	var scoreRe *regexp.Regexp
+	switch metric {
+	case metricVMAF:
+		filter, scoreRe = "libvmaf", vmafScoreRe
+	case metricSSIM:
+		filter, scoreRe = "ssim", ssimScoreRe
+	default:
+		filter, scoreRe = "psnr", psnrScoreRe
+	}
+
+	ref := strings.Join(append(append([]string{}, refFilters...), "setpts=PTS-STARTPTS"), ",")
+	filterGraph := fmt.Sprintf("[0:v]scale=%d:%d:flags=bicubic,setpts=PTS-STARTPTS[dist];[1:v]%s[ref];[dist][ref]%s", width, height, ref, filter)
+	args := append([]string{"-hide_banner"}, inputs...)
+	args = append(args,
+		"-filter_complex", filterGraph,
+		"-progress", TempTCPProgress(duration),
+		"-f", "null", "-",
+	)
+	output, err := runFFmpegLog(args...)
+	if err != nil {
+		log.Printf("Error occurred while scoring quality: %v", err)
+		return nil
+	}
+
+	match := scoreRe.FindStringSubmatch(output)
+	if match == nil {
+		log.Println("Could not find a quality score in the ffmpeg output")
+		return nil
+	}
+	if match[1] == "inf" { // identical frames
+		score.Score = 100
+		return score
+	}
+	score.Score, err = strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		log.Println("Error parsing quality score:", err)
+		return nil
+	}
+	log.Printf("%s score: %v", score.Metric, score.Score)
+	return score
+}
+
+func (q QualityScore) String() string {
+	var s string
+	if q.Metric == "SSIM" {
+		s = fmt.Sprintf("%s: %.4f", q.Metric, q.Score)
+	} else {
+		s = fmt.Sprintf("%s: %.2f", q.Metric, q.Score)
+	}
+	if q.Note != "" {
+		s += " (" + q.Note + ")"
+	}
+	return s
+}
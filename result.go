@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Summary of the last finished encode, shown in the completion popup and optionally saved as JSON
+type EncodeResult struct {
+	Source      string           `json:"source"`
+	Output      string           `json:"output"`
+	Platform    string           `json:"platform,omitempty"`
+	TargetBytes int64            `json:"target_bytes,omitempty"`
+	OutputBytes int64            `json:"output_bytes,omitempty"`
+	Warnings    []string         `json:"warnings,omitempty"`
+	Notes       []string         `json:"notes,omitempty"`
+	AutoCodec   *AutoCodecChoice `json:"auto_codec,omitempty"`
+	Quality     *QualityScore    `json:"quality,omitempty"`
+	Voice       *VoiceMessage    `json:"voice_message,omitempty"`
+	Loudness    *LoudnessResult  `json:"loudness,omitempty"`
+	AudioChoice string           `json:"audio_choice,omitempty"`
+}
+
+var lastResult EncodeResult
+
+// Lines shown under "Encoding finished!"
+func (r EncodeResult) summary() []string {
+	var lines []string
+	if r.OutputBytes > 0 && r.TargetBytes > 0 {
+		lines = append(lines, "Size: "+formatBytes(r.OutputBytes)+" of "+formatBytes(r.TargetBytes))
+	}
+	lines = append(lines, r.Warnings...)
+	lines = append(lines, r.Notes...)
+	if r.AudioChoice != "" {
+		lines = append(lines, r.AudioChoice)
+	}
+	if r.AutoCodec != nil {
+		lines = append(lines, r.AutoCodec.String())
+	}
+	if r.Quality != nil {
+		lines = append(lines, "Quality "+r.Quality.String())
+	}
+	if r.Loudness != nil {
+		lines = append(lines, r.Loudness.String())
+	}
+	if r.Voice != nil {
+		lines = append(lines, fmt.Sprintf("Voice message: %.2f seconds, waveform saved to %s", r.Voice.DurationSecs, filepath.Base(r.Voice.Sidecar)))
+	}
+	return lines
+}
+
+// Logs the result and writes it next to the output file when enabled
+func saveResult(r EncodeResult) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		log.Println("Error creating result json:", err)
+		return
+	}
+	log.Printf("result: %s", data)
+	if !saveResultJSON || r.Output == "" {
+		return
+	}
+	err = os.WriteFile(r.Output+".json", data, 0644)
+	if err != nil {
+		log.Println("Error writing result json:", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Target size units, indexes match sizeUnitNames. MB and KB are decimal, MiB and KiB are binary
+var sizeUnitNames = []string{"MB", "MiB", "KB", "KiB", "bytes"}
+var sizeUnitBytes = []float64{1000 * 1000, 1024 * 1024, 1000, 1024, 1}
+
+const megabyte = 1000 * 1000
+
+// Returns the unit index for a unit name, or -1 if unknown
+func sizeUnitIndex(name string) int {
+	for i, n := range sizeUnitNames {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Converts a size typed in the GUI to an exact byte count.
+// Rounds down so the byte limit is never above what was typed
+func parseTargetSize(str string, unit int) (int64, error) {
+	size, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || math.IsNaN(size) || math.IsInf(size, 0) {
+		return 0, errors.New("target size must be a number")
+	}
+	if unit < 0 || unit >= len(sizeUnitBytes) {
+		return 0, errors.New("unknown size unit")
+	}
+	bytes := math.Floor(size * sizeUnitBytes[unit])
+	if bytes < 1000 {
+		return 0, errors.New("target size must be at least 1 KB")
+	}
+	if bytes > 100000*megabyte {
+		return 0, errors.New("target size must be at most 100000 MB")
+	}
+	return int64(bytes), nil
+}
+
+// Formats a byte count with thousands separators, e.g. "10,485,760 bytes"
+func formatBytes(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	var sb strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(d)
+	}
+	return sb.String() + " bytes"
+}
+
+// Compares the encoded file against the byte limit it was encoded for.
+// Returns the file size and a warning if it went over
+func verifyOutputSize(outputPath string, targetBytes int64) (int64, string) {
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return 0, "Couldn't check the size of the output file"
+	}
+	if info.Size() > targetBytes {
+		return info.Size(), "Output is " + formatBytes(info.Size()-targetBytes) + " over the target size, try again with a smaller target"
+	}
+	return info.Size(), ""
+}
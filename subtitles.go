@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Subtitle codecs that are pictures instead of text, these are drawn with overlay
+var bitmapSubtitleCodecs = []string{"hdmv_pgs_subtitle", "dvd_subtitle", "dvb_subtitle", "xsub"}
+
+// Subtitle files that can be burned in from outside the video
+var subtitleFileExtensions = []string{"srt", "ass", "ssa", "vtt"}
+
+// A subtitle stream of the selected file
+type SubtitleTrack struct {
+	Number   int // position among the subtitle streams, used as 0:s:Number
+	Codec    string
+	Title    string
+	Language string
+}
+
+func (t SubtitleTrack) bitmap() bool {
+	for _, codec := range bitmapSubtitleCodecs {
+		if t.Codec == codec {
+			return true
+		}
+	}
+	return false
+}
+
+func (t SubtitleTrack) label() string {
+	label := fmt.Sprintf("Subtitles %d", t.Number+1)
+	if t.Title != "" {
+		label += ": " + t.Title
+	}
+	if t.Language != "" && t.Language != "und" {
+		label += " (" + t.Language + ")"
+	}
+	return label
+}
+
+// Subtitle streams of the selected file, filled in with the audio tracks when a file is selected
+var subtitleTracks []SubtitleTrack
+
+func listSubtitleTracks(mediaInfo MediaInfo) []SubtitleTrack {
+	var tracks []SubtitleTrack
+	for _, s := range mediaInfo.Streams {
+		if s.CodecType != "subtitle" {
+			continue
+		}
+		tracks = append(tracks, SubtitleTrack{
+			Number:   len(tracks),
+			Codec:    s.CodecName,
+			Title:    s.Tags.Title,
+			Language: s.Tags.Language,
+		})
+	}
+	return tracks
+}
+
+// Subtitles to burn into the video, either a stream of the source or a separate file
+type SubtitleOptions struct {
+	Source string // the video the subtitle stream is read from
+	Track  int    // -1 when Path is used
+	Bitmap bool
+	Path   string
+	Offset float64 // seconds cut from the start of the source, the subtitles are shifted to match
+}
+
+func (s SubtitleOptions) enabled() bool {
+	return s.Track >= 0 || s.Path != ""
+}
+
+// Returns the filters that draw text subtitles. The subtitle renderer uses the timestamps of the
+// source, so after an input seek the frames are moved back to their original time while drawing
+func (s SubtitleOptions) filters() []string {
+	if !s.enabled() || s.Bitmap {
+		return nil
+	}
+	var draw string
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(s.Path), ".")); {
+	case s.Path == "":
+		draw = fmt.Sprintf("subtitles=filename=%s:si=%d", filterPath(s.Source), s.Track)
+	case ext == "ass" || ext == "ssa":
+		draw = "ass=filename=" + filterPath(s.Path)
+	default:
+		draw = "subtitles=filename=" + filterPath(s.Path)
+	}
+	if s.Offset <= 0 {
+		return []string{draw}
+	}
+	return []string{fmt.Sprintf("setpts=PTS+%.3f/TB", s.Offset), draw, "setpts=PTS-STARTPTS"}
+}
+
+// Returns the start of a filter graph that draws bitmap subtitles over the first video stream.
+// The subtitle stream is seeked along with the video so no offset is needed
+func (s SubtitleOptions) overlay() string {
+	if !s.enabled() || !s.Bitmap {
+		return ""
+	}
+	return fmt.Sprintf("[0:v:0][0:s:%d]overlay=(W-w)/2:H-h", s.Track)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ffmpeg "github.com/Gordon-T/ffmpeg-go"
+)
+
+// An audio stream of the selected file and how it should be used in the output
+type AudioTrack struct {
+	Number   int // position among the audio streams, used as 0:a:Number
+	Title    string
+	Language string
+	Enabled  bool
+	Volume   int32 // percent
+
+	// Set for audio from another file that is added to the video instead of a stream of the source
+	Path string
+	Loop bool
+}
+
+// Returns the start of the filter chain that reads the track. Audio from other files is
+// read with amovie and padded or looped forever, the output is cut to the video with -shortest
+func (t AudioTrack) input() string {
+	if t.Path == "" {
+		return fmt.Sprintf("[0:a:%d]", t.Number)
+	}
+	if t.Loop {
+		return "amovie=" + filterPath(t.Path) + ":loop=0,asetpts=N/SR/TB,"
+	}
+	return "amovie=" + filterPath(t.Path) + ",apad,"
+}
+
+// Escapes a file path for use as a filter option inside a filter graph
+func filterPath(path string) string {
+	return filterText(strings.ReplaceAll(path, `\`, "/"))
+}
+
+// Checks if any track comes from another file
+func hasAddedAudio(tracks []AudioTrack) bool {
+	for _, t := range tracks {
+		if t.Enabled && t.Path != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (t AudioTrack) label() string {
+	label := fmt.Sprintf("Track %d", t.Number+1)
+	if t.Title != "" {
+		label += ": " + t.Title
+	}
+	if t.Language != "" && t.Language != "und" {
+		label += " (" + t.Language + ")"
+	}
+	return label
+}
+
+// Audio tracks of the selected file, filled in when a file is selected
+var audioTracks []AudioTrack
+var audioTracksFile string
+var selectedDuration float64 // seconds, used to preview settings before encoding
+
+// Probes the file, lists its audio and subtitle streams and keeps its duration. Only the first
+// track is enabled to match what ffmpeg picks when no tracks are chosen
+func loadAudioTracks(fileName string) {
+	audioTracks = nil
+	subtitleTracks = nil
+	selectedSubtitle = 0
+	audioTracksFile = fileName
+	selectedDuration = 0
+	info, err := ffmpeg.Probe(fileName)
+	if err != nil {
+		log.Println("Error listing audio tracks:", err)
+		return
+	}
+	var mediaInfo MediaInfo
+	err = json.Unmarshal([]byte(info), &mediaInfo)
+	if err != nil {
+		log.Println("Error parsing json data from ffprobe:", err)
+		return
+	}
+	selectedDuration, _ = strconv.ParseFloat(mediaInfo.Format.Duration, 64)
+	subtitleTracks = listSubtitleTracks(mediaInfo)
+	for _, s := range mediaInfo.Streams {
+		if s.CodecType != "audio" {
+			continue
+		}
+		audioTracks = append(audioTracks, AudioTrack{
+			Number:   len(audioTracks),
+			Title:    s.Tags.Title,
+			Language: s.Tags.Language,
+			Enabled:  len(audioTracks) == 0,
+			Volume:   100,
+		})
+	}
+}
+
+func enabledTracks(tracks []AudioTrack) []AudioTrack {
+	var enabled []AudioTrack
+	for _, t := range tracks {
+		if t.Enabled {
+			enabled = append(enabled, t)
+		}
+	}
+	return enabled
+}
+
+// Returns the ffmpeg arguments that pick, mix and filter the audio tracks.
+// filters are applied after mixing. With no tracks listed ffmpeg's default stream is used,
+// and with every track disabled the output has no audio
+func audioTrackArgs(tracks []AudioTrack, filters []string) ffmpeg.KwArgs {
+	if len(tracks) == 0 {
+		if len(filters) == 0 {
+			return ffmpeg.KwArgs{}
+		}
+		return ffmpeg.KwArgs{"af": strings.Join(filters, ",")}
+	}
+	enabled := enabledTracks(tracks)
+	if len(enabled) == 0 {
+		return ffmpeg.KwArgs{"an": ""}
+	}
+
+	// A single track of the source at full volume can be mapped straight from the input
+	if len(enabled) == 1 && enabled[0].Volume == 100 && enabled[0].Path == "" {
+		args := ffmpeg.KwArgs{"map": fmt.Sprintf("0:a:%d", enabled[0].Number)}
+		if len(filters) > 0 {
+			args["af"] = strings.Join(filters, ",")
+		}
+		return args
+	}
+
+	// Otherwise build a filter graph, -af can't be used on its output so the filters go in the graph too
+	var graph []string
+	var mixInputs string
+	for i, t := range enabled {
+		graph = append(graph, fmt.Sprintf("%svolume=%.2f[a%d]", t.input(), float64(t.Volume)/100, i))
+		mixInputs += fmt.Sprintf("[a%d]", i)
+	}
+	chain := []string{"anull"}
+	if len(enabled) > 1 {
+		// Added audio never ends, so the mix follows the source tracks which come first
+		duration := "longest"
+		if hasAddedAudio(enabled) {
+			duration = "first"
+		}
+		// normalize=0 keeps each track at its own volume instead of dividing by the track count
+		chain = []string{fmt.Sprintf("amix=inputs=%d:duration=%s:normalize=0", len(enabled), duration)}
+	}
+	chain = append(chain, filters...)
+	graph = append(graph, mixInputs+strings.Join(chain, ",")+"[aout]")
+	return ffmpeg.KwArgs{
+		"filter_complex": strings.Join(graph, ";"),
+		"map":            "[aout]",
+	}
+}
+
+// Adds the audio track arguments to a video encode, mapping the video alongside the audio
+// since -map turns off ffmpeg's automatic stream selection. A video filter graph from
+// applyVideoOptions is joined with the audio graph
+func applyVideoAudioTracks(ffmpegArguments ffmpeg.KwArgs, tracks []AudioTrack, filters []string) {
+	audioArgs := audioTrackArgs(tracks, filters)
+	videoMap := "0:v:0"
+	if videoGraph, ok := ffmpegArguments["filter_complex"].(string); ok {
+		videoMap = "[vout]"
+		if audioGraph, ok := audioArgs["filter_complex"].(string); ok {
+			audioArgs["filter_complex"] = videoGraph + ";" + audioGraph
+		}
+		if _, ok := audioArgs["map"]; !ok {
+			// The default audio stream isn't picked once the video comes from a graph
+			audioArgs["map"] = "0:a:0?"
+		}
+	}
+	if audioMap, ok := audioArgs["map"]; ok {
+		audioArgs["map"] = []string{videoMap, audioMap.(string)}
+	}
+	addArgs(ffmpegArguments, audioArgs)
+	if hasAddedAudio(tracks) {
+		ffmpegArguments["shortest"] = ""
+	}
+}
+
+// Ways of adding audio from another file to a video, indexes match addedAudioModeNames
+const (
+	addedAudioOff = iota
+	addedAudioReplace
+	addedAudioOverlay
+)
+
+var addedAudioModeNames = []string{"Original", "Replace", "Overlay"}
+
+// Adds the audio file to the tracks of the source. Replacing disables the source tracks,
+// overlaying mixes the file under them
+func addAudioFile(tracks []AudioTrack, mode int, path string, loop bool, volume int32) []AudioTrack {
+	if mode == addedAudioOff {
+		return tracks
+	}
+	if mode == addedAudioReplace {
+		for i := range tracks {
+			tracks[i].Enabled = false
+		}
+	}
+	return append(tracks, AudioTrack{
+		Number:  len(tracks),
+		Title:   filepath.Base(path),
+		Enabled: true,
+		Volume:  volume,
+		Path:    path,
+		Loop:    loop,
+	})
+}
+
+// Checks the track selection can make an audio file
+func checkAudioTracks(tracks []AudioTrack) error {
+	if len(tracks) > 0 && len(enabledTracks(tracks)) == 0 {
+		return errors.New("select at least one audio track")
+	}
+	return nil
+}
+
+// Lists the tracks only when the path was typed in instead of selected
+func refreshAudioTracks() {
+	if audioTracksFile != filePath {
+		loadAudioTracks(filePath)
+	}
+}
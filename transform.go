@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Crop modes, indexes match cropModeNames
+const (
+	cropOff = iota
+	cropAuto
+	cropManual
+)
+
+var cropModeNames = []string{"Off", "Auto", "Manual"}
+
+// Rotations applied after cropping, indexes match rotationNames
+var rotationNames = []string{"None", "90° Right", "180°", "90° Left"}
+
+// Points through the video where black bars are looked for, as fractions of the duration
+var cropSamplePoints = []float64{0.1, 0.3, 0.5, 0.7, 0.9}
+
+const cropSampleFrames = 12
+
+var cropDetectRe = regexp.MustCompile(`crop=(\d+):(\d+):(\d+):(\d+)`)
+
+// Part of the frame to keep, in the upright orientation the video is played in
+type CropRect struct {
+	Width  int
+	Height int
+	X      int
+	Y      int
+}
+
+// Written the same way as ffmpeg's crop filter options
+func (c CropRect) String() string {
+	return fmt.Sprintf("%d:%d:%d:%d", c.Width, c.Height, c.X, c.Y)
+}
+
+// Reads a crop rectangle typed as width:height:x:y and checks it fits in the frame
+func parseCrop(s string, width int, height int) (CropRect, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 4 {
+		return CropRect{}, errors.New("crop must be written as width:height:x:y")
+	}
+	var values [4]int
+	for i, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || v < 0 {
+			return CropRect{}, errors.New("crop must be written as width:height:x:y")
+		}
+		values[i] = v
+	}
+	c := CropRect{Width: values[0], Height: values[1], X: values[2], Y: values[3]}
+	if c.Width < 16 || c.Height < 16 {
+		return CropRect{}, errors.New("crop must be at least 16x16")
+	}
+	if c.X+c.Width > width || c.Y+c.Height > height {
+		return CropRect{}, fmt.Errorf("crop doesn't fit in the %dx%d video", width, height)
+	}
+	// yuv420p needs even sizes
+	c.Width, c.Height = c.Width/2*2, c.Height/2*2
+	return c, nil
+}
+
+// Runs cropdetect on a few frames at several points of the video and returns the smallest
+// rectangle that keeps the picture of every sample, so a dark scene can't cut off a bright one
+func detectCrop(filePath string, duration float64) (CropRect, error) {
+	left, top, right, bottom := math.MaxInt, math.MaxInt, 0, 0
+	for _, point := range cropSamplePoints {
+		start := strconv.FormatFloat(duration*point, 'f', 3, 64)
+		output, err := runFFmpegLog("-hide_banner", "-ss", start, "-i", filePath,
+			"-vf", "cropdetect=limit=24:round=2:reset=0", "-frames:v", strconv.Itoa(cropSampleFrames), "-an", "-f", "null", "-")
+		if err != nil {
+			log.Printf("Error detecting black bars at %ss: %v", start, err)
+			continue
+		}
+		matches := cropDetectRe.FindAllStringSubmatch(output, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		// cropdetect keeps refining with reset=0, so the last line covers every frame of the sample
+		last := matches[len(matches)-1]
+		w, _ := strconv.Atoi(last[1])
+		h, _ := strconv.Atoi(last[2])
+		x, _ := strconv.Atoi(last[3])
+		y, _ := strconv.Atoi(last[4])
+		left, top = min(left, x), min(top, y)
+		right, bottom = max(right, x+w), max(bottom, y+h)
+	}
+	if right == 0 {
+		return CropRect{}, errors.New("couldn't find the picture in any sampled frame")
+	}
+	crop := CropRect{Width: (right - left) / 2 * 2, Height: (bottom - top) / 2 * 2, X: left, Y: top}
+	log.Println("Detected crop:", crop)
+	return crop, nil
+}
+
+// Crop, rotation and flips applied to the video before it is scaled
+type VideoTransform struct {
+	Crop     CropRect // Width 0 keeps the whole frame
+	Rotation int      // index into rotationNames
+	FlipH    bool
+	FlipV    bool
+}
+
+// Checks if the output frame differs from the source frame
+func (t VideoTransform) changed() bool {
+	return t.Crop.Width > 0 || t.Rotation != 0 || t.FlipH || t.FlipV
+}
+
+// Returns the size of the frame after cropping and rotating
+func (t VideoTransform) size(width int, height int) (int, int) {
+	if t.Crop.Width > 0 {
+		width, height = t.Crop.Width, t.Crop.Height
+	}
+	if t.Rotation == 1 || t.Rotation == 3 {
+		width, height = height, width
+	}
+	return width, height
+}
+
+func (t VideoTransform) filters() []string {
+	var filters []string
+	if t.Crop.Width > 0 {
+		filters = append(filters, "crop="+t.Crop.String())
+	}
+	switch t.Rotation {
+	case 1:
+		filters = append(filters, "transpose=clock")
+	case 2:
+		filters = append(filters, "hflip", "vflip")
+	case 3:
+		filters = append(filters, "transpose=cclock")
+	}
+	if t.FlipH {
+		filters = append(filters, "hflip")
+	}
+	if t.FlipV {
+		filters = append(filters, "vflip")
+	}
+	return filters
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ffmpeg "github.com/Gordon-T/ffmpeg-go"
+)
+
+const (
+	voiceBitrate         = 32   // kb/s, plenty for speech in mono Opus
+	voiceWaveformSamples = 256  // Discord draws this many bars
+	voiceWaveformRate    = 8000 // sample rate the waveform is worked out from
+)
+
+// Attachment fields Discord needs to show a file as a voice message
+type VoiceMessage struct {
+	DurationSecs float64 `json:"duration_secs"`
+	Waveform     string  `json:"waveform"` // base64 of 256 bytes, one amplitude per bar
+	Sidecar      string  `json:"-"`
+}
+
+// Encodes a Discord voice message, mono 48 kHz Opus in an .ogg container.
+// Returns the output path, or "" if encoding failed
+func voiceEncode(filePath string, opts AudioOptions) string {
+	var fileName string = filepath.Base(filePath)
+	var outputName = filepath.Dir(filePath) + `\` + strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "_voice.ogg"
+
+	ffmpegArguments := ffmpeg.KwArgs{
+		"vn":          "",
+		"c:a":         "libopus",
+		"b:a":         fmt.Sprintf("%dk", voiceBitrate),
+		"application": "voip",
+		"ac":          "1",
+		"ar":          "48000",
+	}
+	addArgs(ffmpegArguments, audioTrackArgs(opts.Tracks, opts.Filters))
+	addArgs(ffmpegArguments, oggMetadataArgs(opts.Metadata, opts.Tags))
+	voiceErr := ffmpeg.Input(filePath).Output(outputName, ffmpegArguments).GlobalArgs("-progress", TempTCPProgress(opts.Duration)).OverWriteOutput().SetFfmpegPath("./ffmpeg.exe").ErrorToStdOut().Run()
+
+	if voiceErr != nil {
+		encodeError = true
+		log.Printf("Error occurred while encoding voice message: %v", voiceErr)
+		return ""
+	}
+	log.Println("Encoded voice message!")
+	return outputName
+}
+
+// Decodes the voice message, works out its duration and waveform and writes them next to it as JSON
+func writeVoiceSidecar(outputPath string) (*VoiceMessage, error) {
+	pcm, err := runFFmpegStdout("-v", "error", "-i", outputPath, "-ac", "1", "-ar", fmt.Sprint(voiceWaveformRate), "-f", "s16le", "-")
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]int16, len(pcm)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+	}
+	if len(samples) == 0 {
+		return nil, errors.New("voice message has no audio")
+	}
+
+	voice := &VoiceMessage{
+		DurationSecs: math.Round(float64(len(samples))/voiceWaveformRate*100) / 100,
+		Waveform:     base64.StdEncoding.EncodeToString(voiceWaveform(samples)),
+		Sidecar:      strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".json",
+	}
+	data, err := json.MarshalIndent(voice, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return voice, os.WriteFile(voice.Sidecar, data, 0644)
+}
+
+// Splits the samples into 256 bars and scales the RMS of each bar to 0-255, the loudest bar being 255
+func voiceWaveform(samples []int16) []byte {
+	levels := make([]float64, voiceWaveformSamples)
+	loudest := 0.0
+	for bar := range levels {
+		start := bar * len(samples) / voiceWaveformSamples
+		end := (bar + 1) * len(samples) / voiceWaveformSamples
+		if end <= start {
+			// Clips shorter than 256 samples repeat samples across bars
+			end = start + 1
+		}
+		var sum float64
+		for _, s := range samples[start:end] {
+			sum += float64(s) * float64(s)
+		}
+		levels[bar] = math.Sqrt(sum / float64(end-start))
+		loudest = math.Max(loudest, levels[bar])
+	}
+
+	waveform := make([]byte, voiceWaveformSamples)
+	if loudest == 0 {
+		return waveform
+	}
+	for bar, level := range levels {
+		waveform[bar] = byte(math.Round(level / loudest * 255))
+	}
+	return waveform
+}